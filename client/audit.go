@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type AuditEntry struct {
+	AuditID    int64           `json:"audit_id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Diff       json.RawMessage `json:"diff"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows the audit log. Zero values are left out of the query.
+type AuditFilter struct {
+	ActorID    int64
+	TargetType string
+	TargetID   int64
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+func (f AuditFilter) query() url.Values {
+	qs := url.Values{}
+
+	if f.ActorID != 0 {
+		qs.Set("actor_id", strconv.FormatInt(f.ActorID, 10))
+	}
+	if f.TargetType != "" {
+		qs.Set("target_type", f.TargetType)
+	}
+	if f.TargetID != 0 {
+		qs.Set("target_id", strconv.FormatInt(f.TargetID, 10))
+	}
+	if !f.From.IsZero() {
+		qs.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		qs.Set("to", f.To.Format(time.RFC3339))
+	}
+	if f.Limit != 0 {
+		qs.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset != 0 {
+		qs.Set("offset", strconv.Itoa(f.Offset))
+	}
+
+	return qs
+}
+
+func (c *Client) ListAudit(ctx context.Context, filter AuditFilter) ([]*AuditEntry, Metadata, error) {
+	var resp struct {
+		Audit    []*AuditEntry `json:"audit"`
+		Metadata Metadata      `json:"metadata"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/audit", filter.query(), nil, &resp)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return resp.Audit, resp.Metadata, nil
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Content struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+	URL   string `json:"url"`
+}
+
+type Banner struct {
+	BannerID  int64      `json:"banner_id"`
+	FeatureID int64      `json:"feature_id"`
+	TagIDs    []int64    `json:"tag_ids"`
+	Content   Content    `json:"content"`
+	IsActive  bool       `json:"is_active"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+type Metadata struct {
+	Offset       int `json:"offset"`
+	Limit        int `json:"limit"`
+	TotalRecords int `json:"total_records"`
+}
+
+// BannerFilter narrows banner lists. Zero values are left out of the query.
+type BannerFilter struct {
+	FeatureID int64
+	TagID     int64
+	Limit     int
+	Offset    int
+}
+
+func (f BannerFilter) query() url.Values {
+	qs := url.Values{}
+
+	if f.FeatureID != 0 {
+		qs.Set("feature_id", strconv.FormatInt(f.FeatureID, 10))
+	}
+	if f.TagID != 0 {
+		qs.Set("tag_id", strconv.FormatInt(f.TagID, 10))
+	}
+	if f.Limit != 0 {
+		qs.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset != 0 {
+		qs.Set("offset", strconv.Itoa(f.Offset))
+	}
+
+	return qs
+}
+
+type CreateBannerInput struct {
+	TagIDs    []int64 `json:"tag_ids"`
+	FeatureID int64   `json:"feature_id"`
+	Content   Content `json:"content"`
+	IsActive  bool    `json:"is_active"`
+}
+
+// UpdateBannerInput only changes the fields that are set. Empty content
+// fields keep their current value.
+type UpdateBannerInput struct {
+	TagIDs    []int64  `json:"tag_ids,omitempty"`
+	FeatureID *int64   `json:"feature_id,omitempty"`
+	Content   *Content `json:"content,omitempty"`
+	IsActive  *bool    `json:"is_active,omitempty"`
+}
+
+// GetUserBanner returns the content of the banner shown for a feature and
+// tag.
+func (c *Client) GetUserBanner(ctx context.Context, featureID, tagID int64, useLastRevision bool) (*Content, error) {
+	qs := url.Values{}
+	qs.Set("feature_id", strconv.FormatInt(featureID, 10))
+	qs.Set("tag_id", strconv.FormatInt(tagID, 10))
+	if useLastRevision {
+		qs.Set("use_last_revision", "true")
+	}
+
+	var resp struct {
+		Content Content `json:"content"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/user_banner", qs, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp.Content, nil
+}
+
+func (c *Client) ListBanners(ctx context.Context, filter BannerFilter) ([]*Banner, Metadata, error) {
+	var resp struct {
+		Banners  []*Banner `json:"banners"`
+		Metadata Metadata  `json:"metadata"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/banner", filter.query(), nil, &resp)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return resp.Banners, resp.Metadata, nil
+}
+
+func (c *Client) CreateBanner(ctx context.Context, input CreateBannerInput) (int64, error) {
+	var resp struct {
+		BannerID int64 `json:"banner_id"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/banner", nil, input, &resp)
+	if err != nil {
+		return 0, err
+	}
+
+	return resp.BannerID, nil
+}
+
+func (c *Client) UpdateBanner(ctx context.Context, bannerID int64, input UpdateBannerInput) (*Banner, error) {
+	var resp struct {
+		Banner *Banner `json:"banner"`
+	}
+
+	err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/banner/%d", bannerID), nil, input, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Banner, nil
+}
+
+// DeleteBanner moves a banner to the trash.
+func (c *Client) DeleteBanner(ctx context.Context, bannerID int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/banner/%d", bannerID), nil, nil, nil)
+}
+
+// ListTrash returns soft-deleted banners that haven't been purged yet.
+func (c *Client) ListTrash(ctx context.Context, filter BannerFilter) ([]*Banner, Metadata, error) {
+	var resp struct {
+		Banners  []*Banner `json:"banners"`
+		Metadata Metadata  `json:"metadata"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/banner/trash", filter.query(), nil, &resp)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return resp.Banners, resp.Metadata, nil
+}
+
+func (c *Client) RestoreBanner(ctx context.Context, bannerID int64) (*Banner, error) {
+	var resp struct {
+		Banner *Banner `json:"banner"`
+	}
+
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/banner/%d/restore", bannerID), nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Banner, nil
+}
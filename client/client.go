@@ -0,0 +1,164 @@
+// Package client is a typed Go client for the banner service API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const problemContentType = "application/problem+json"
+
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+type Option func(*Client)
+
+// WithToken authenticates every request with a bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client. It shouldn't set Timeout,
+// which would also cut off event streams and exports; bound calls with their
+// context instead.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Transport: newTransport()},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// anonymous returns a copy of c that sends no bearer token, for requests
+// that carry their credentials in the body. A stored token may have expired
+// and must not get them rejected.
+func (c *Client) anonymous() *Client {
+	cp := *c
+	cp.token = ""
+	return &cp
+}
+
+// newTransport bounds connecting and waiting for response headers, but not
+// reading the body, so that event streams and exports can run for as long as
+// the caller's context allows.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.DialContext = (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ResponseHeaderTimeout = 30 * time.Second
+
+	return transport
+}
+
+// do sends body as JSON and decodes a 2xx response into dst. Other responses
+// are returned as *APIError.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, dst any) error {
+	var rd io.Reader
+
+	header := http.Header{}
+
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		rd = bytes.NewReader(js)
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.send(ctx, method, path, query, header, rd)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if dst == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(dst)
+	if err != nil {
+		return fmt.Errorf("client: decoding %s %s response: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// send performs the request and returns the response of a 2xx status, which
+// the caller must close. Other responses are returned as *APIError.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, query, header, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, newAPIError(resp)
+	}
+
+	return resp, nil
+}
+
+// newRequest builds an authenticated request. Accept defaults to JSON unless
+// header sets it, and always lists problem+json for errors.
+func (c *Client) newRequest(ctx context.Context, method, path string, query url.Values, header http.Header, body io.Reader) (*http.Request, error) {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", "application/json, "+problemContentType)
+	} else {
+		req.Header.Set("Accept", req.Header.Get("Accept")+", "+problemContentType)
+	}
+
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	return req, nil
+}
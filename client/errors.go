@@ -0,0 +1,160 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// APIError is a non-2xx response decoded from a problem+json body or the
+// legacy error envelope. Message holds the error detail, Fields the per-field
+// messages of a failed validation and Lines the per-line report of a rejected
+// import. Code and RequestID are only set by problem+json responses.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Fields     map[string]string
+	Lines      []ImportLineError
+	RequestID  string
+}
+
+type ImportLineError struct {
+	Line   int               `json:"line"`
+	Errors map[string]string `json:"errors"`
+}
+
+func (e *APIError) Error() string {
+	if len(e.Lines) > 0 {
+		return fmt.Sprintf("%d %s: %d invalid lines", e.StatusCode, http.StatusText(e.StatusCode), len(e.Lines))
+	}
+
+	if len(e.Fields) == 0 {
+		return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	}
+
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key+" "+e.Fields[key])
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), strings.Join(parts, "; "))
+}
+
+// StatusCode returns the HTTP status of an *APIError anywhere in err's chain,
+// or 0.
+func StatusCode(err error) int {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.StatusCode
+	}
+	return 0
+}
+
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
+func IsForbidden(err error) bool {
+	return StatusCode(err) == http.StatusForbidden
+}
+
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+func IsRateLimited(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests
+}
+
+// ValidationErrors returns the per-field messages of a failed validation, or
+// nil when err isn't one.
+func ValidationErrors(err error) map[string]string {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.Fields
+	}
+	return nil
+}
+
+// ErrorCode returns the stable problem code of an *APIError anywhere in err's
+// chain, or an empty string.
+func ErrorCode(err error) string {
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		return apiError.Code
+	}
+	return ""
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	apiError := &APIError{StatusCode: resp.StatusCode}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1_048_576))
+	if err != nil {
+		apiError.Message = err.Error()
+		return apiError
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == problemContentType {
+		var problem struct {
+			Code     string            `json:"code"`
+			Detail   string            `json:"detail"`
+			Instance string            `json:"instance"`
+			Errors   map[string]string `json:"errors"`
+			Lines    []ImportLineError `json:"lines"`
+		}
+
+		if json.Unmarshal(body, &problem) == nil {
+			apiError.Code = problem.Code
+			apiError.Message = problem.Detail
+			apiError.Fields = problem.Errors
+			apiError.Lines = problem.Lines
+			apiError.RequestID = problem.Instance
+			return apiError
+		}
+	}
+
+	var env struct {
+		Error json.RawMessage `json:"error"`
+	}
+
+	if json.Unmarshal(body, &env) != nil || len(env.Error) == 0 {
+		apiError.Message = strings.TrimSpace(string(body))
+		return apiError
+	}
+
+	if json.Unmarshal(env.Error, &apiError.Message) == nil {
+		return apiError
+	}
+
+	if json.Unmarshal(env.Error, &apiError.Fields) == nil {
+		apiError.Message = "failed validation"
+		return apiError
+	}
+
+	if json.Unmarshal(env.Error, &apiError.Lines) == nil {
+		apiError.Message = "failed import"
+		return apiError
+	}
+
+	apiError.Message = string(env.Error)
+
+	return apiError
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Event struct {
+	EventID  int64           `json:"event_id"`
+	Type     string          `json:"type"`
+	BannerID int64           `json:"banner_id"`
+	Banner   json.RawMessage `json:"banner"`
+	// PreviousFeatureID is set on an update that moved the banner to
+	// another feature.
+	PreviousFeatureID *int64    `json:"previous_feature_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// SubscribeEvents reads the banner event stream and calls fn for every event
+// until ctx is done, the server closes the stream or fn returns an error.
+// Events after lastEventID are replayed first; 0 starts with new events.
+func (c *Client) SubscribeEvents(ctx context.Context, lastEventID int64, fn func(*Event) error) error {
+	header := http.Header{}
+	header.Set("Accept", "text/event-stream")
+	if lastEventID > 0 {
+		header.Set("Last-Event-ID", strconv.FormatInt(lastEventID, 10))
+	}
+
+	resp, err := c.send(ctx, http.MethodGet, "/banner/events", nil, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+
+	var payload strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			if payload.Len() == 0 {
+				continue
+			}
+
+			var event Event
+
+			err := json.Unmarshal([]byte(payload.String()), &event)
+			if err != nil {
+				return fmt.Errorf("client: decoding event: %w", err)
+			}
+			payload.Reset()
+
+			err = fn(&event)
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			if payload.Len() > 0 {
+				payload.WriteByte('\n')
+			}
+			payload.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type Readiness struct {
+	Ready  bool            `json:"ready"`
+	Checks map[string]bool `json:"checks"`
+}
+
+// Healthcheck returns the service status, "available" while it serves
+// requests.
+func (c *Client) Healthcheck(ctx context.Context) (string, error) {
+	var resp struct {
+		Status string `json:"status"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/healthz", nil, nil, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Status, nil
+}
+
+// Readiness reports whether the service is ready to serve traffic. A 503
+// isn't an error here: it returns the failed checks with Ready unset.
+func (c *Client) Readiness(ctx context.Context) (*Readiness, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, "/readyz", nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, newAPIError(resp)
+	}
+
+	var readiness Readiness
+
+	err = json.NewDecoder(resp.Body).Decode(&readiness)
+	if err != nil {
+		return nil, fmt.Errorf("client: decoding readiness response: %w", err)
+	}
+
+	return &readiness, nil
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// ImportResult reports an import. BannerIDs is empty for a dry run, which
+// only counts the valid banners.
+type ImportResult struct {
+	DryRun    bool    `json:"dry_run"`
+	Valid     int     `json:"valid"`
+	Imported  int     `json:"imported"`
+	BannerIDs []int64 `json:"banner_ids"`
+}
+
+// ExportBanners streams banners in the given format, FormatNDJSON by default.
+// The caller must close the returned reader.
+func (c *Client) ExportBanners(ctx context.Context, filter BannerFilter, format string) (io.ReadCloser, error) {
+	qs := filter.query()
+	if format != "" {
+		qs.Set("format", format)
+	}
+
+	header := http.Header{}
+	header.Set("Accept", "application/x-ndjson, text/csv, application/json")
+
+	resp, err := c.send(ctx, http.MethodGet, "/banner/export", qs, header, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// ImportBanners creates the banners read from r in one transaction. A
+// rejected import returns an *APIError with the per-line report in Lines.
+func (c *Client) ImportBanners(ctx context.Context, r io.Reader, format string, dryRun bool) (*ImportResult, error) {
+	header := http.Header{}
+	if format == FormatCSV {
+		header.Set("Content-Type", "text/csv")
+	} else {
+		header.Set("Content-Type", "application/x-ndjson")
+	}
+
+	var qs url.Values
+	if dryRun {
+		qs = url.Values{"dry_run": {strconv.FormatBool(dryRun)}}
+	}
+
+	resp, err := c.send(ctx, http.MethodPost, "/banner/import", qs, header, r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ImportResult
+
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("client: decoding import response: %w", err)
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,225 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type User struct {
+	UserID    int64     `json:"user_id"`
+	UserName  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateUserInput struct {
+	UserName string `json:"username"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+// UserFilter narrows user lists. Zero values are left out of the query.
+type UserFilter struct {
+	Role   string
+	Limit  int
+	Offset int
+}
+
+func (f UserFilter) query() url.Values {
+	qs := url.Values{}
+
+	if f.Role != "" {
+		qs.Set("role", f.Role)
+	}
+	if f.Limit != 0 {
+		qs.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset != 0 {
+		qs.Set("offset", strconv.Itoa(f.Offset))
+	}
+
+	return qs
+}
+
+// UpdateUserInput only changes the fields that are set. Changing the role or
+// password revokes all of the user's tokens.
+type UpdateUserInput struct {
+	UserName *string `json:"username,omitempty"`
+	Role     *string `json:"role,omitempty"`
+	Password *string `json:"password,omitempty"`
+}
+
+// CreateUser registers a user and returns it with its first token.
+func (c *Client) CreateUser(ctx context.Context, input CreateUserInput) (*User, string, error) {
+	var resp struct {
+		User  *User  `json:"user"`
+		Token string `json:"token"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/user", nil, input, &resp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return resp.User, resp.Token, nil
+}
+
+// CreateToken logs in and returns a new token. It neither sends nor changes
+// the client's token.
+func (c *Client) CreateToken(ctx context.Context, username, password string) (string, error) {
+	input := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{username, password}
+
+	var resp struct {
+		NewToken struct {
+			Token string `json:"token"`
+		} `json:"new_token"`
+	}
+
+	err := c.anonymous().do(ctx, http.MethodPost, "/token", nil, input, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.NewToken.Token, nil
+}
+
+// TokenPair is what a login returns from a server running with
+// -token-mode=signed: a short-lived access token and the refresh token that
+// renews it.
+type TokenPair struct {
+	AccessToken  string
+	Expiry       time.Time
+	RefreshToken string
+}
+
+// CreateTokenPair logs in to a server that issues signed access tokens. It
+// neither sends nor changes the client's token.
+func (c *Client) CreateTokenPair(ctx context.Context, username, password string) (TokenPair, error) {
+	input := struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}{username, password}
+
+	var resp struct {
+		NewToken struct {
+			Token  string    `json:"token"`
+			Expiry time.Time `json:"expiry"`
+		} `json:"new_token"`
+		RefreshToken struct {
+			Token string `json:"token"`
+		} `json:"refresh_token"`
+	}
+
+	err := c.anonymous().do(ctx, http.MethodPost, "/token", nil, input, &resp)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  resp.NewToken.Token,
+		Expiry:       resp.NewToken.Expiry,
+		RefreshToken: resp.RefreshToken.Token,
+	}, nil
+}
+
+// RefreshToken returns a new access token and its expiry for a refresh
+// token. It neither sends nor changes the client's token, which is usually
+// the expired access token.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (string, time.Time, error) {
+	input := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{refreshToken}
+
+	var resp struct {
+		NewToken struct {
+			Token  string    `json:"token"`
+			Expiry time.Time `json:"expiry"`
+		} `json:"new_token"`
+	}
+
+	err := c.anonymous().do(ctx, http.MethodPost, "/token/refresh", nil, input, &resp)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return resp.NewToken.Token, resp.NewToken.Expiry, nil
+}
+
+// RevokeTokens revokes every token of the authenticated user, including the
+// one the client sends.
+func (c *Client) RevokeTokens(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/token", nil, nil, nil)
+}
+
+func (c *Client) ListUsers(ctx context.Context, filter UserFilter) ([]*User, Metadata, error) {
+	var resp struct {
+		Users    []*User  `json:"users"`
+		Metadata Metadata `json:"metadata"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/users", filter.query(), nil, &resp)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return resp.Users, resp.Metadata, nil
+}
+
+func (c *Client) GetUser(ctx context.Context, userID int64) (*User, error) {
+	var resp struct {
+		User *User `json:"user"`
+	}
+
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d", userID), nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.User, nil
+}
+
+func (c *Client) UpdateUser(ctx context.Context, userID int64, input UpdateUserInput) (*User, error) {
+	var resp struct {
+		User *User `json:"user"`
+	}
+
+	err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/users/%d", userID), nil, input, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.User, nil
+}
+
+// DeleteUser removes a user and their tokens.
+func (c *Client) DeleteUser(ctx context.Context, userID int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/users/%d", userID), nil, nil, nil)
+}
+
+// ChangePassword changes the authenticated user's password. Every token of
+// the user is revoked, including the one the client sends, and a new token
+// is returned.
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, password string) (string, error) {
+	input := struct {
+		CurrentPassword string `json:"current_password"`
+		Password        string `json:"password"`
+	}{currentPassword, password}
+
+	var resp struct {
+		Token string `json:"token"`
+	}
+
+	err := c.do(ctx, http.MethodPatch, "/me", nil, input, &resp)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Token, nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestTokenRequestsAreAnonymous checks that logging in and refreshing don't
+// send the client's token, which the server would reject once it expired.
+func TestTokenRequestsAreAnonymous(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("%s %s sent Authorization: %s", r.Method, r.URL.Path, auth)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]any{
+			"new_token":     map[string]any{"token": "access", "expiry": "2030-01-01T00:00:00Z"},
+			"refresh_token": map[string]any{"token": "refresh"},
+		})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithToken("expired"))
+	ctx := context.Background()
+
+	if _, err := c.CreateToken(ctx, "alice", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	pair, err := c.CreateTokenPair(ctx, "alice", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.AccessToken != "access" || pair.RefreshToken != "refresh" {
+		t.Errorf("got %+v", pair)
+	}
+
+	if _, _, err := c.RefreshToken(ctx, "refresh"); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.token != "expired" {
+		t.Errorf("client token changed to %q", c.token)
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Webhook struct {
+	WebhookID  int64     `json:"webhook_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	FeatureIDs []int64   `json:"feature_ids"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// CreateWebhookInput registers a webhook. The service generates the secret
+// when it is empty; it is only returned by CreateWebhook.
+type CreateWebhookInput struct {
+	URL        string  `json:"url"`
+	Secret     string  `json:"secret,omitempty"`
+	FeatureIDs []int64 `json:"feature_ids,omitempty"`
+	IsActive   *bool   `json:"is_active,omitempty"`
+}
+
+func (c *Client) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	var resp struct {
+		Webhooks []*Webhook `json:"webhooks"`
+	}
+
+	err := c.do(ctx, http.MethodGet, "/webhooks", nil, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Webhooks, nil
+}
+
+func (c *Client) CreateWebhook(ctx context.Context, input CreateWebhookInput) (*Webhook, error) {
+	var resp struct {
+		Webhook *Webhook `json:"webhook"`
+	}
+
+	err := c.do(ctx, http.MethodPost, "/webhooks", nil, input, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Webhook, nil
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, webhookID int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/webhooks/%d", webhookID), nil, nil, nil)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+func (app *application) listAuditHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.AuditFilters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.ActorID = app.readInt(qs, "actor_id", 0, data.ReadIntOptions{Required: false, IsID: true}, v)
+	filters.TargetType = qs.Get("target_type")
+	filters.TargetID = app.readInt(qs, "target_id", 0, data.ReadIntOptions{Required: false, IsID: true}, v)
+	filters.From = app.readTime(qs, "from", v)
+	filters.To = app.readTime(qs, "to", v)
+	filters.Limit = app.readInt(qs, "limit", 10, data.ReadIntOptions{Required: false, IsID: false}, v)
+	filters.Offset = app.readInt(qs, "offset", 0, data.ReadIntOptions{Required: false, IsID: false}, v)
+
+	if data.ValidateAuditFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	entries, metadata, err := app.models.Audit.GetAll(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "audit": entries}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
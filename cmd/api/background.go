@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Error(fmt.Sprintf("%v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
+
+func (app *application) startTrashPurger(ctx context.Context) {
+	if app.config.trash.retention <= 0 {
+		return
+	}
+
+	app.background(func() {
+		ticker := time.NewTicker(app.config.trash.purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				purged, err := app.models.Banners.Purge(ctx, app.config.trash.retention)
+				if err != nil {
+					app.logger.Error(err.Error(), "task", "trash purger")
+					continue
+				}
+
+				if purged > 0 {
+					app.logger.Info("purged deleted banners", "count", purged)
+				}
+			}
+		}
+	})
+}
+
+// startLoginSweeper forgets idle login failure counters, so that sweeping
+// never runs on the request path.
+func (app *application) startLoginSweeper(ctx context.Context) {
+	app.background(func() {
+		ticker := time.NewTicker(app.config.login.lockout)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				app.logins.sweep()
+			}
+		}
+	})
+}
@@ -26,11 +26,14 @@ func (app *application) showBannerHandler(w http.ResponseWriter, r *http.Request
 
 	user := app.contextGetUser(r)
 
-	banner, err := app.models.Banners.Get(filters, user.Role)
+	banner, stale, err := app.getUserBanner(r.Context(), filters)
+	if err == nil {
+		err = banner.CheckAccess(user.Role)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.bannerNotFoundResponse(w, r)
 			return
 		case errors.Is(err, data.ErrForbiddenAccess):
 			app.forbiddenAccessResponse(w, r)
@@ -41,6 +44,11 @@ func (app *application) showBannerHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
+	if stale {
+		setStale(w)
+		app.staleResponses.Add(1)
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"content": banner.Content}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -65,7 +73,7 @@ func (app *application) listFilteredBannersHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	banners, metadata, err := app.models.Banners.GetAll(filters)
+	banners, metadata, err := app.models.Banners.GetAll(r.Context(), filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -105,7 +113,7 @@ func (app *application) createBannerHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Banners.Insert(banner)
+	err = app.models.Banners.Insert(r.Context(), banner, app.contextGetActor(r))
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -120,14 +128,15 @@ func (app *application) createBannerHandler(w http.ResponseWriter, r *http.Reque
 func (app *application) updateBannerHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil || id < 1 {
-		app.notFoundResponse(w, r)
+		app.bannerNotFoundResponse(w, r)
+		return
 	}
 
-	banner, err := app.models.Banners.GetByID(id)
+	banner, err := app.models.Banners.GetByID(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.bannerNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -178,7 +187,7 @@ func (app *application) updateBannerHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Banners.Update(banner)
+	err = app.models.Banners.Update(r.Context(), banner, app.contextGetActor(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -198,14 +207,15 @@ func (app *application) updateBannerHandler(w http.ResponseWriter, r *http.Reque
 func (app *application) deleteBannerHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil || id < 1 {
-		app.notFoundResponse(w, r)
+		app.bannerNotFoundResponse(w, r)
+		return
 	}
 
-	err = app.models.Banners.Delete(id)
+	err = app.models.Banners.Delete(r.Context(), id, app.contextGetActor(r))
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
-			app.notFoundResponse(w, r)
+			app.bannerNotFoundResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -217,3 +227,56 @@ func (app *application) deleteBannerHandler(w http.ResponseWriter, r *http.Reque
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) listTrashHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.AdminFilters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.FeatureID = app.readInt(qs, "feature_id", 0, data.ReadIntOptions{Required: false, IsID: true}, v)
+	filters.TagID = app.readInt(qs, "tag_id", 0, data.ReadIntOptions{Required: false, IsID: true}, v)
+	filters.Limit = app.readInt(qs, "limit", 10, data.ReadIntOptions{Required: false, IsID: false}, v)
+	filters.Offset = app.readInt(qs, "offset", 0, data.ReadIntOptions{Required: false, IsID: false}, v)
+
+	if data.ValidateNonRequiredFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	banners, metadata, err := app.models.Banners.GetTrash(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"metadata": metadata, "banners": banners}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreBannerHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.bannerNotFoundResponse(w, r)
+		return
+	}
+
+	banner, err := app.models.Banners.Restore(r.Context(), id, app.contextGetActor(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.bannerNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"banner": banner}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"expvar"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+)
+
+// maxCachedTokens bounds the tokens remembered for outages.
+const maxCachedTokens = 100_000
+
+// databaseUnavailable reports whether err comes from the breaker refusing to
+// connect.
+func databaseUnavailable(err error) bool {
+	return errors.Is(err, data.ErrUnavailable)
+}
+
+// keepSnapshot reports whether the banner snapshot is loaded, either to serve
+// from or to fall back on while the breaker is open.
+func (app *application) keepSnapshot() bool {
+	return app.config.snapshot.enabled || app.config.breaker.enabled
+}
+
+// requireDatabase fails requests fast with 503 while the breaker is open.
+func (app *application) requireDatabase(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.breaker.Open() {
+			app.databaseUnavailableResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) databaseUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	retryAfter := max(math.Ceil(app.breaker.RetryAfter().Seconds()), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter)))
+
+	app.serviceUnavailableResponse(w, r)
+}
+
+// setStale marks a response served from the snapshot while the database is
+// unreachable.
+func setStale(w http.ResponseWriter) {
+	w.Header().Set("Warning", `110 - "Response is Stale"`)
+	w.Header().Set("X-Stale", "true")
+}
+
+// publishBreakerMetrics exposes the breaker on /debug/vars.
+func (app *application) publishBreakerMetrics() {
+	expvar.Publish("db_breaker_state", expvar.Func(func() any {
+		return app.breaker.State()
+	}))
+	expvar.Publish("db_breaker_trips", expvar.Func(func() any {
+		return app.breaker.Trips()
+	}))
+	expvar.Publish("stale_responses", &app.staleResponses)
+}
+
+// tokenCache remembers recently verified tokens so that users stay
+// authenticated while the breaker is open. It is never consulted while the
+// database answers, so revocations apply as usual.
+type tokenCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]tokenCacheEntry
+}
+
+type tokenCacheEntry struct {
+	user     *data.User
+	verified time.Time
+}
+
+func newTokenCache(ttl time.Duration) *tokenCache {
+	return &tokenCache{
+		ttl:     ttl,
+		entries: make(map[[sha256.Size]byte]tokenCacheEntry),
+	}
+}
+
+func (c *tokenCache) set(token string, user *data.User) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCachedTokens {
+		for hash, entry := range c.entries {
+			if time.Since(entry.verified) > c.ttl {
+				delete(c.entries, hash)
+			}
+		}
+
+		if len(c.entries) >= maxCachedTokens {
+			return
+		}
+	}
+
+	c.entries[sha256.Sum256([]byte(token))] = tokenCacheEntry{user: user, verified: time.Now()}
+}
+
+func (c *tokenCache) get(token string) (*data.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[sha256.Sum256([]byte(token))]
+	if !ok || time.Since(entry.verified) > c.ttl {
+		return nil, false
+	}
+
+	return entry.user, true
+}
+
+// forgetUser drops every token of the user, after they were revoked.
+func (c *tokenCache) forgetUser(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for hash, entry := range c.entries {
+		if entry.user.UserID == userID {
+			delete(c.entries, hash)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+)
+
+// bannerCachePrefix starts every key the service puts in the cache.
+const bannerCachePrefix = "banner:"
+
+func bannerCacheKey(featureID, tagID int64) string {
+	return fmt.Sprintf("%s%d:%d", bannerCachePrefix, featureID, tagID)
+}
+
+// notFoundValue is cached for a feature and tag without a banner.
+var notFoundValue = []byte("null")
+
+// cacheLoads tracks the banner queries in flight, so that one that was
+// overtaken by an invalidation of its key doesn't cache the banner it read
+// before the change.
+type cacheLoads struct {
+	mu    sync.Mutex
+	loads map[string]*cacheLoad
+}
+
+type cacheLoad struct {
+	invalidated bool
+}
+
+func newCacheLoads() *cacheLoads {
+	return &cacheLoads{loads: make(map[string]*cacheLoad)}
+}
+
+// start registers a query for key. Queries for a key are coalesced, so there
+// is at most one at a time.
+func (c *cacheLoads) start(key string) *cacheLoad {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	load := &cacheLoad{}
+	c.loads[key] = load
+
+	return load
+}
+
+func (c *cacheLoads) finish(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.loads, key)
+}
+
+func (c *cacheLoads) invalidated(load *cacheLoad) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return load.invalidated
+}
+
+// invalidate marks the queries for keys as overtaken, or every query when
+// keys is empty.
+func (c *cacheLoads) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(keys) == 0 {
+		for _, load := range c.loads {
+			load.invalidated = true
+		}
+		return
+	}
+
+	for _, key := range keys {
+		if load, ok := c.loads[key]; ok {
+			load.invalidated = true
+		}
+	}
+}
+
+// getUserBanner serves the banner for a feature and tag from the snapshot or
+// the cache unless the caller asked for the last revision. Concurrent misses
+// for the same key share a single query. While the breaker is open the
+// snapshot is served even when it is disabled, and stale is true.
+func (app *application) getUserBanner(ctx context.Context, filters data.UserFilters) (banner *data.Banner, stale bool, err error) {
+	if filters.UseLastRevision {
+		banner, err := app.models.Banners.Get(ctx, filters)
+		return banner, false, err
+	}
+
+	if app.config.snapshot.enabled {
+		banner, ok := app.snapshot.get(int64(filters.FeatureID), int64(filters.TagID))
+		if ok {
+			return banner, app.breaker.Open(), nil
+		}
+	}
+
+	key := bannerCacheKey(int64(filters.FeatureID), int64(filters.TagID))
+
+	if app.config.cache.enabled {
+		js, ok, err := app.cache.Get(key)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "cache")
+		}
+
+		if ok {
+			if bytes.Equal(js, notFoundValue) {
+				return nil, false, data.ErrRecordNotFound
+			}
+
+			var banner data.Banner
+
+			err := json.Unmarshal(js, &banner)
+			if err == nil {
+				return &banner, false, nil
+			}
+		}
+	}
+
+	// The shared query must not fail for every waiter when the first caller
+	// goes away, so it only keeps the model's own timeout.
+	v, err, _ := app.bannerGroup.Do(key, func() (any, error) {
+		load := app.cacheLoads.start(key)
+		defer app.cacheLoads.finish(key)
+
+		banner, err := app.models.Banners.Get(context.WithoutCancel(ctx), filters)
+		if app.config.cache.enabled {
+			app.cacheBanner(key, load, banner, err)
+		}
+		return banner, err
+	})
+	if err != nil {
+		if databaseUnavailable(err) && app.keepSnapshot() {
+			banner, ok := app.snapshot.get(int64(filters.FeatureID), int64(filters.TagID))
+			if ok {
+				return banner, true, nil
+			}
+		}
+		return nil, false, err
+	}
+
+	return v.(*data.Banner), false, nil
+}
+
+// cacheBanner stores the result of a banner query, caching ErrRecordNotFound
+// for the shorter negative ttl. Other errors aren't cached, and neither are
+// results of a query that an invalidation of the key overtook.
+func (app *application) cacheBanner(key string, load *cacheLoad, banner *data.Banner, err error) {
+	var (
+		js  []byte
+		ttl time.Duration
+	)
+
+	switch {
+	case err == nil:
+		js, err = json.Marshal(banner)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "cache")
+			return
+		}
+		ttl = app.liveConfig().cache.ttl
+	case errors.Is(err, data.ErrRecordNotFound):
+		js = notFoundValue
+		ttl = app.liveConfig().cache.negativeTTL
+	default:
+		return
+	}
+
+	if app.cacheLoads.invalidated(load) {
+		return
+	}
+
+	err = app.cache.Set(key, js, ttl)
+	if err != nil {
+		app.logger.Error(err.Error(), "task", "cache")
+		return
+	}
+
+	// The invalidation may have deleted the key between the check and the
+	// set, so the set has to be undone.
+	if app.cacheLoads.invalidated(load) {
+		err = app.cache.Delete(key)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "cache")
+		}
+	}
+}
+
+// startCacheInvalidator evicts cached banners changed through any replica.
+// Notifications sent while the listener connection is down are lost, so the
+// whole cache is flushed and the snapshot reloaded whenever it drops.
+func (app *application) startCacheInvalidator(ctx context.Context) {
+	if !app.config.cache.enabled && !app.keepSnapshot() {
+		return
+	}
+
+	notify := app.listen(ctx, "cache invalidator", data.BannerCacheChannel)
+
+	app.background(func() {
+		sweep := time.NewTicker(app.config.cache.ttl)
+		defer sweep.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-notify:
+				// A nil notification marks a lost or restored connection.
+				if n == nil {
+					app.flushCaches()
+					continue
+				}
+				app.invalidateCache(ctx, n.Payload)
+			case <-sweep.C:
+				if c, ok := app.cache.(interface{ DeleteExpired() }); ok {
+					c.DeleteExpired()
+				}
+			}
+		}
+	})
+}
+
+func (app *application) invalidateCache(ctx context.Context, payload string) {
+	var pairs []data.BannerCacheInvalidation
+
+	err := json.Unmarshal([]byte(payload), &pairs)
+	if err != nil {
+		if payload != "flush" {
+			app.logger.Error(err.Error(), "task", "cache invalidator")
+		}
+		app.flushCaches()
+		return
+	}
+
+	var (
+		keys         []string
+		snapshotKeys []snapshotKey
+	)
+
+	for _, pair := range pairs {
+		for _, tagID := range pair.TagIDs {
+			keys = append(keys, bannerCacheKey(pair.FeatureID, tagID))
+			snapshotKeys = append(snapshotKeys, snapshotKey{pair.FeatureID, tagID})
+		}
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	// Queries in flight are marked before the delete, so that either they
+	// see the mark or the delete comes after their set.
+	app.cacheLoads.invalidate(keys...)
+
+	err = app.cache.Delete(keys...)
+	if err != nil {
+		app.logger.Error(err.Error(), "task", "cache invalidator")
+	}
+
+	app.snapshot.evict(snapshotKeys...)
+
+	// A read served by a lagging replica right after the eviction can cache
+	// the old banner again, so evict once more after the lag has passed,
+	// unless the server shuts down first.
+	if app.replicas.Len() > 0 {
+		app.background(func() {
+			timer := time.NewTimer(app.config.db.replicas.maxLag)
+			defer timer.Stop()
+
+			select {
+			case <-ctx.Done():
+			case <-timer.C:
+				err := app.cache.Delete(keys...)
+				if err != nil {
+					app.logger.Error(err.Error(), "task", "cache invalidator")
+				}
+			}
+		})
+	}
+}
+
+func (app *application) flushCaches() {
+	app.cacheLoads.invalidate()
+
+	err := app.cache.Flush()
+	if err != nil {
+		app.logger.Error(err.Error(), "task", "cache invalidator")
+	}
+
+	if app.keepSnapshot() {
+		app.snapshot.requestReload()
+	}
+}
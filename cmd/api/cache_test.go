@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+)
+
+func TestCacheBannerSkipsOvertakenQueries(t *testing.T) {
+	app := newTestApplication(t, nil)
+
+	banner := &data.Banner{BannerID: 1, FeatureID: 1, TagIDs: []int64{1, 2}}
+
+	changed := bannerCacheKey(1, 1)
+	untouched := bannerCacheKey(1, 3)
+
+	changedLoad := app.cacheLoads.start(changed)
+	untouchedLoad := app.cacheLoads.start(untouched)
+
+	// The banner changes while both queries run.
+	app.invalidateCache(context.Background(), `[{"feature_id":1,"tag_ids":[1,2]}]`)
+
+	app.cacheBanner(changed, changedLoad, banner, nil)
+	app.cacheBanner(untouched, untouchedLoad, banner, nil)
+
+	app.cacheLoads.finish(changed)
+	app.cacheLoads.finish(untouched)
+
+	if _, ok, _ := app.cache.Get(changed); ok {
+		t.Error("cached the banner read before the invalidation")
+	}
+	if _, ok, _ := app.cache.Get(untouched); !ok {
+		t.Error("didn't cache the banner of an unchanged key")
+	}
+
+	// A query that starts after the invalidation caches as usual.
+	load := app.cacheLoads.start(changed)
+	app.cacheBanner(changed, load, banner, nil)
+	app.cacheLoads.finish(changed)
+
+	if _, ok, _ := app.cache.Get(changed); !ok {
+		t.Error("didn't cache the banner read after the invalidation")
+	}
+}
+
+func TestFlushCachesOvertakesEveryQuery(t *testing.T) {
+	app := newTestApplication(t, nil)
+
+	key := bannerCacheKey(2, 2)
+
+	load := app.cacheLoads.start(key)
+	app.flushCaches()
+	app.cacheBanner(key, load, nil, data.ErrRecordNotFound)
+	app.cacheLoads.finish(key)
+
+	if _, ok, _ := app.cache.Get(key); ok {
+		t.Error("cached a not found result read before the flush")
+	}
+}
+
+func TestInvalidateCacheEvictsAgainAfterReplicaLag(t *testing.T) {
+	app := newTestApplication(t, nil)
+	app.replicas = data.NewReplicaSet(nil, data.Replica{Name: "replica"})
+	app.config.db.replicas.maxLag = 10 * time.Millisecond
+
+	key := bannerCacheKey(3, 3)
+
+	app.invalidateCache(context.Background(), `[{"feature_id":3,"tag_ids":[3]}]`)
+
+	// A lagging replica served the old banner right after the eviction.
+	err := app.cache.Set(key, notFoundValue, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.wg.Wait()
+
+	if _, ok, _ := app.cache.Get(key); ok {
+		t.Error("kept the banner cached during the replica lag")
+	}
+}
+
+func TestInvalidateCacheStopsOnShutdown(t *testing.T) {
+	app := newTestApplication(t, nil)
+	app.replicas = data.NewReplicaSet(nil, data.Replica{Name: "replica"})
+	app.config.db.replicas.maxLag = time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	app.invalidateCache(ctx, `[{"feature_id":3,"tag_ids":[3]}]`)
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the second eviction outlived the shutdown")
+	}
+}
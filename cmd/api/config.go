@@ -0,0 +1,548 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "BANNER_"
+
+type config struct {
+	port            int
+	shutdownTimeout time.Duration
+	logLevel        slog.Level
+	server          struct {
+		readHeaderTimeout time.Duration
+		readTimeout       time.Duration
+		writeTimeout      time.Duration
+		idleTimeout       time.Duration
+	}
+	tls struct {
+		certFile       string
+		keyFile        string
+		clientCAFile   string
+		reloadInterval time.Duration
+	}
+	db struct {
+		dsn          string
+		maxOpenConns int
+		maxIdleConns int
+		maxIdleTime  time.Duration
+		timeouts     data.Timeouts
+		replicas     struct {
+			dsns          []string
+			checkInterval time.Duration
+			maxLag        time.Duration
+		}
+	}
+	breaker struct {
+		enabled  bool
+		failures int
+		cooldown time.Duration
+		tokenTTL time.Duration
+	}
+	token struct {
+		mode      string
+		accessTTL time.Duration
+		keys      []string
+	}
+	password struct {
+		minLength    int
+		denylistFile string
+	}
+	login struct {
+		maxFailures   int
+		ipMaxFailures int
+		lockout       time.Duration
+		maxLockout    time.Duration
+	}
+	limiter struct {
+		rps     float64
+		burst   int
+		enabled bool
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	events struct {
+		pollInterval time.Duration
+		retention    time.Duration
+		maxHold      time.Duration
+	}
+	cache struct {
+		enabled     bool
+		ttl         time.Duration
+		negativeTTL time.Duration
+		backend     string
+		redis       struct {
+			addr     string
+			password string
+			db       int
+			poolSize int
+			timeout  time.Duration
+		}
+	}
+	snapshot struct {
+		enabled         bool
+		refreshInterval time.Duration
+	}
+	webhooks struct {
+		workers      int
+		timeout      time.Duration
+		maxAttempts  int
+		backoff      time.Duration
+		pollInterval time.Duration
+		retention    time.Duration
+	}
+}
+
+// secretSettings are redacted when the config is printed.
+var secretSettings = map[string]bool{
+	"db-dsn":               true,
+	"db-replica-dsns":      true,
+	"cache-redis-password": true,
+	"token-keys":           true,
+}
+
+// metaSettings pick the config file and mode. They aren't read from the file
+// or the BANNER_* environment.
+var metaSettings = map[string]bool{
+	"config":       true,
+	"config-check": true,
+}
+
+func registerFlags(fs *flag.FlagSet, cfg *config) {
+	fs.IntVar(&cfg.port, "port", 8080, "API server port")
+	fs.DurationVar(&cfg.shutdownTimeout, "shutdown-timeout", 10*time.Second, "Time in-flight requests get to finish on shutdown")
+	fs.TextVar(&cfg.logLevel, "log-level", slog.LevelInfo, "Minimum log level (debug|info|warn|error)")
+
+	fs.DurationVar(&cfg.server.readHeaderTimeout, "server-read-header-timeout", 5*time.Second, "Time to read request headers")
+	fs.DurationVar(&cfg.server.readTimeout, "server-read-timeout", 30*time.Second, "Time to read a whole request, including the body")
+	fs.DurationVar(&cfg.server.writeTimeout, "server-write-timeout", 30*time.Second, "Time to write a response, except for event and export streams")
+	fs.DurationVar(&cfg.server.idleTimeout, "server-idle-timeout", time.Minute, "Time a keep-alive connection waits for the next request")
+
+	fs.StringVar(&cfg.tls.certFile, "tls-cert-file", "", "TLS certificate file, serves HTTPS when set with -tls-key-file")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key-file", "", "TLS private key file")
+	fs.StringVar(&cfg.tls.clientCAFile, "tls-client-ca-file", "", "CA bundle for client certificates, required on admin routes when set")
+	fs.DurationVar(&cfg.tls.reloadInterval, "tls-reload-interval", 30*time.Second, "Interval between checks of the TLS certificate files for changes")
+
+	fs.StringVar(&cfg.db.dsn, "db-dsn", "", "PostgreSQL DSN")
+	fs.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 1200, "PostgreSQL max open connections")
+	fs.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 600, "PostgreSQL max idle connections")
+	fs.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 10*time.Minute, "PostgreSQL max connection idle time")
+	fs.DurationVar(&cfg.db.timeouts.Read, "db-read-timeout", data.DefaultTimeouts.Read, "Timeout of single-row and page queries (0 keeps only the request deadline)")
+	fs.DurationVar(&cfg.db.timeouts.Write, "db-write-timeout", data.DefaultTimeouts.Write, "Timeout of single-record writes (0 keeps only the request deadline)")
+	fs.DurationVar(&cfg.db.timeouts.Bulk, "db-bulk-timeout", data.DefaultTimeouts.Bulk, "Timeout of exports, imports, snapshots and purges (0 keeps only the caller's deadline)")
+
+	fs.Var(listValue{&cfg.db.replicas.dsns}, "db-replica-dsns", "Comma-separated PostgreSQL DSNs of read replicas for banner queries")
+	fs.DurationVar(&cfg.db.replicas.checkInterval, "db-replica-check-interval", 5*time.Second, "Interval between read replica health checks")
+	fs.DurationVar(&cfg.db.replicas.maxLag, "db-replica-max-lag", time.Second, "Expected replica lag; cached banners are evicted again after it passes")
+
+	fs.BoolVar(&cfg.breaker.enabled, "breaker-enabled", false, "Fail fast while the primary database refuses connections and serve user banners from the snapshot, which is then always loaded")
+	fs.IntVar(&cfg.breaker.failures, "breaker-failures", 5, "Failed connection attempts in a row that open the breaker")
+	fs.DurationVar(&cfg.breaker.cooldown, "breaker-cooldown", 10*time.Second, "Time the breaker stays open before probing the database")
+	fs.DurationVar(&cfg.breaker.tokenTTL, "breaker-token-ttl", 10*time.Minute, "Time a verified token is still accepted while the breaker is open (0 rejects them)")
+
+	fs.StringVar(&cfg.token.mode, "token-mode", "opaque", "Bearer tokens (opaque|signed), signed issues short-lived access tokens checked without the database")
+	fs.DurationVar(&cfg.token.accessTTL, "token-access-ttl", 15*time.Minute, "Lifetime of signed access tokens")
+	fs.Var(listValue{&cfg.token.keys}, "token-keys", "Comma-separated kid:alg:base64key signing keys, the first one signs (alg is HS256 or EdDSA)")
+
+	fs.IntVar(&cfg.password.minLength, "password-min-length", data.DefaultPasswordMinLength, "Minimum length of new passwords in bytes")
+	fs.StringVar(&cfg.password.denylistFile, "password-denylist-file", "", "File of passwords to reject on top of the built-in common ones, one per line")
+
+	fs.IntVar(&cfg.login.maxFailures, "login-max-failures", 5, "Failed logins in a row that lock a username out")
+	fs.IntVar(&cfg.login.ipMaxFailures, "login-ip-max-failures", 20, "Failed logins in a row that lock a client IP out")
+	fs.DurationVar(&cfg.login.lockout, "login-lockout", time.Minute, "First lockout, doubled on every further failure")
+	fs.DurationVar(&cfg.login.maxLockout, "login-max-lockout", time.Hour, "Longest lockout")
+
+	fs.Float64Var(&cfg.limiter.rps, "limiter-rps", 1000, "Rate limiter maximum requests per second")
+	fs.IntVar(&cfg.limiter.burst, "limiter-burst", 1000, "Rate limiter maximum burst")
+	fs.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+
+	fs.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "Time deleted banners are kept before purging (0 disables purging)")
+	fs.DurationVar(&cfg.trash.purgeInterval, "trash-purge-interval", time.Hour, "Interval between trash purges")
+
+	fs.DurationVar(&cfg.events.pollInterval, "events-poll-interval", 30*time.Second, "Interval between banner event log polls when no notifications arrive")
+	fs.DurationVar(&cfg.events.retention, "events-retention", 7*24*time.Hour, "Time banner events are kept for Last-Event-ID resumption")
+	fs.DurationVar(&cfg.events.maxHold, "events-max-hold", 10*time.Second, "Longest time banner events wait for older running transactions before they are sent anyway")
+
+	fs.BoolVar(&cfg.cache.enabled, "cache-enabled", true, "Cache user banners in memory")
+	fs.DurationVar(&cfg.cache.ttl, "cache-ttl", 5*time.Minute, "Time a user banner stays cached")
+	fs.DurationVar(&cfg.cache.negativeTTL, "cache-negative-ttl", 5*time.Second, "Time a missing user banner stays cached")
+	fs.StringVar(&cfg.cache.backend, "cache-backend", "memory", "Cache backend (memory|redis)")
+	fs.StringVar(&cfg.cache.redis.addr, "cache-redis-addr", "localhost:6379", "Address of the Redis protocol cache server")
+	fs.StringVar(&cfg.cache.redis.password, "cache-redis-password", "", "Password of the Redis protocol cache server")
+	fs.IntVar(&cfg.cache.redis.db, "cache-redis-db", 0, "Database number on the Redis protocol cache server")
+	fs.IntVar(&cfg.cache.redis.poolSize, "cache-redis-pool-size", 64, "Maximum idle connections to the Redis protocol cache server")
+	fs.DurationVar(&cfg.cache.redis.timeout, "cache-redis-timeout", 100*time.Millisecond, "Redis protocol cache dial and command timeout")
+
+	fs.BoolVar(&cfg.snapshot.enabled, "snapshot-enabled", false, "Serve user banners from an in-memory snapshot of all active banners")
+	fs.DurationVar(&cfg.snapshot.refreshInterval, "snapshot-refresh-interval", time.Minute, "Interval between banner snapshot reloads")
+
+	fs.IntVar(&cfg.webhooks.workers, "webhooks-workers", 2, "Number of webhook delivery workers")
+	fs.DurationVar(&cfg.webhooks.timeout, "webhooks-timeout", 5*time.Second, "Webhook delivery request timeout")
+	fs.IntVar(&cfg.webhooks.maxAttempts, "webhooks-max-attempts", 10, "Webhook delivery attempts before giving up")
+	fs.DurationVar(&cfg.webhooks.backoff, "webhooks-backoff", 10*time.Second, "Delay before the first webhook retry, doubled on every further attempt")
+	fs.DurationVar(&cfg.webhooks.pollInterval, "webhooks-poll-interval", time.Second, "Interval between webhook outbox polls when it is empty")
+	fs.DurationVar(&cfg.webhooks.retention, "webhooks-retention", 7*24*time.Hour, "Time delivered and abandoned webhook deliveries are kept")
+}
+
+// configSource is how the config was loaded: the config file and whether to
+// only check it.
+type configSource struct {
+	file  string
+	check bool
+}
+
+// parseConfig builds the config from the command line args and the layers
+// under it. settings holds the raw value of every setting by name.
+func parseConfig(args []string, errorHandling flag.ErrorHandling, output io.Writer) (cfg config, settings map[string]string, src configSource, err error) {
+	fs := flag.NewFlagSet("api", errorHandling)
+	fs.SetOutput(output)
+
+	registerFlags(fs, &cfg)
+
+	fs.StringVar(&src.file, "config", os.Getenv("BANNER_CONFIG"), "YAML config file, overridden by BANNER_* environment variables and flags")
+	fs.BoolVar(&src.check, "config-check", false, "Validate the config, print it with secrets redacted and exit")
+
+	err = fs.Parse(args)
+	if err != nil {
+		return config{}, nil, src, err
+	}
+
+	err = loadConfigLayers(fs, src.file)
+	if err != nil {
+		return config{}, nil, src, err
+	}
+
+	settings = make(map[string]string)
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if !metaSettings[f.Name] {
+			settings[f.Name] = f.Value.String()
+		}
+	})
+
+	return cfg, settings, src, nil
+}
+
+// loadConfigLayers applies the config file and the environment on top of the
+// flag defaults. Flags given on the command line always win, so fs must be
+// parsed first. Every setting has the same name in all layers: limiter-rps
+// is limiter.rps (or limiter_rps) in the file and BANNER_LIMITER_RPS in the
+// environment.
+func loadConfigLayers(fs *flag.FlagSet, path string) error {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if path != "" {
+		settings, err := readConfigFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range sortedKeys(settings) {
+			if metaSettings[name] || fs.Lookup(name) == nil {
+				return fmt.Errorf("config file %s: unknown setting %q", path, name)
+			}
+
+			if explicit[name] {
+				continue
+			}
+
+			err := fs.Set(name, settings[name])
+			if err != nil {
+				return fmt.Errorf("config file %s: %s: %w", path, name, err)
+			}
+		}
+	}
+
+	var err error
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || metaSettings[f.Name] || explicit[f.Name] {
+			return
+		}
+
+		key := envName(f.Name)
+
+		value, ok := os.LookupEnv(key)
+		if alias := envAliases[f.Name]; !ok && alias != "" {
+			key = alias
+			value, ok = os.LookupEnv(key)
+		}
+		if !ok {
+			return
+		}
+
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("environment %s: %w", key, setErr)
+		}
+	})
+
+	return err
+}
+
+// envAliases are the variables read before the BANNER_* names. They belong to
+// the environment layer, so the config file can't override them, and lose to
+// the BANNER_* name when both are set.
+var envAliases = map[string]string{
+	"db-dsn":               "DB_DSN",
+	"db-replica-dsns":      "DB_REPLICA_DSNS",
+	"cache-redis-password": "CACHE_REDIS_PASSWORD",
+}
+
+func envName(setting string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// readConfigFile flattens a YAML file into setting names, joining nested keys
+// with dashes.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var doc map[string]any
+
+	err = yaml.NewDecoder(f).Decode(&doc)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	settings := make(map[string]string)
+
+	err = flattenSettings("", doc, settings)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return settings, nil
+}
+
+func flattenSettings(prefix string, doc map[string]any, settings map[string]string) error {
+	for key, value := range doc {
+		name := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		if prefix != "" {
+			name = prefix + "-" + name
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			err := flattenSettings(name, value, settings)
+			if err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				switch item.(type) {
+				case map[string]any, []any, nil:
+					return fmt.Errorf("%s: only lists of plain values are supported", name)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			settings[name] = strings.Join(items, ",")
+		case nil:
+			return fmt.Errorf("%s: missing value", name)
+		default:
+			settings[name] = fmt.Sprint(value)
+		}
+	}
+
+	return nil
+}
+
+func validateConfig(v *validator.Validator, cfg config) {
+	v.Check(cfg.port > 0 && cfg.port <= 65535, "port", "must be between 1 and 65535")
+	v.Check(cfg.shutdownTimeout > 0, "shutdown-timeout", "must be greater than zero")
+
+	v.Check(cfg.server.readHeaderTimeout > 0, "server-read-header-timeout", "must be greater than zero")
+	v.Check(cfg.server.readTimeout > 0, "server-read-timeout", "must be greater than zero")
+	v.Check(cfg.server.writeTimeout > 0, "server-write-timeout", "must be greater than zero")
+	v.Check(cfg.server.idleTimeout > 0, "server-idle-timeout", "must be greater than zero")
+
+	v.Check((cfg.tls.certFile == "") == (cfg.tls.keyFile == ""), "tls-key-file", "must be set together with tls-cert-file")
+	v.Check(cfg.tls.clientCAFile == "" || cfg.tls.certFile != "", "tls-client-ca-file", "requires tls-cert-file and tls-key-file")
+	v.Check(cfg.tls.reloadInterval > 0, "tls-reload-interval", "must be greater than zero")
+
+	v.Check(cfg.db.dsn != "", "db-dsn", "must be provided")
+	v.Check(cfg.db.maxOpenConns >= 0, "db-max-open-conns", "must be non-negative")
+	v.Check(cfg.db.maxIdleConns >= 0, "db-max-idle-conns", "must be non-negative")
+	v.Check(cfg.db.maxIdleTime >= 0, "db-max-idle-time", "must be non-negative")
+	v.Check(cfg.db.timeouts.Read >= 0, "db-read-timeout", "must be non-negative")
+	v.Check(cfg.db.timeouts.Write >= 0, "db-write-timeout", "must be non-negative")
+	v.Check(cfg.db.timeouts.Bulk >= 0, "db-bulk-timeout", "must be non-negative")
+	v.Check(cfg.db.replicas.checkInterval > 0, "db-replica-check-interval", "must be greater than zero")
+	v.Check(cfg.db.replicas.maxLag >= 0, "db-replica-max-lag", "must be non-negative")
+
+	if cfg.breaker.enabled {
+		v.Check(cfg.breaker.failures > 0, "breaker-failures", "must be greater than zero")
+		v.Check(cfg.breaker.cooldown > 0, "breaker-cooldown", "must be greater than zero")
+		v.Check(cfg.breaker.tokenTTL >= 0, "breaker-token-ttl", "must be non-negative")
+	}
+
+	v.Check(cfg.token.mode == "opaque" || cfg.token.mode == "signed", "token-mode", "must be either opaque or signed")
+
+	if cfg.token.mode == "signed" {
+		v.Check(cfg.token.accessTTL > 0, "token-access-ttl", "must be greater than zero")
+		v.Check(len(cfg.token.keys) > 0, "token-keys", "must be provided")
+
+		if _, err := loadAccessTokens(cfg); err != nil && len(cfg.token.keys) > 0 {
+			v.AddError("token-keys", err.Error())
+		}
+	}
+
+	v.Check(cfg.password.minLength > 0, "password-min-length", "must be greater than zero")
+	v.Check(cfg.password.minLength <= data.MaxPasswordLength, "password-min-length", fmt.Sprintf("must not be more than %d", data.MaxPasswordLength))
+
+	v.Check(cfg.login.maxFailures > 0, "login-max-failures", "must be greater than zero")
+	v.Check(cfg.login.ipMaxFailures > 0, "login-ip-max-failures", "must be greater than zero")
+	v.Check(cfg.login.lockout > 0, "login-lockout", "must be greater than zero")
+	v.Check(cfg.login.maxLockout >= cfg.login.lockout, "login-max-lockout", "must not be less than login-lockout")
+
+	if cfg.limiter.enabled {
+		v.Check(cfg.limiter.rps > 0, "limiter-rps", "must be greater than zero")
+		v.Check(cfg.limiter.burst > 0, "limiter-burst", "must be greater than zero")
+	}
+
+	v.Check(cfg.trash.retention >= 0, "trash-retention", "must be non-negative")
+	v.Check(cfg.trash.purgeInterval > 0, "trash-purge-interval", "must be greater than zero")
+
+	v.Check(cfg.events.pollInterval > 0, "events-poll-interval", "must be greater than zero")
+	v.Check(cfg.events.retention > 0, "events-retention", "must be greater than zero")
+	v.Check(cfg.events.maxHold > 0, "events-max-hold", "must be greater than zero")
+
+	if cfg.cache.enabled {
+		v.Check(cfg.cache.ttl > 0, "cache-ttl", "must be greater than zero")
+		v.Check(cfg.cache.negativeTTL >= 0, "cache-negative-ttl", "must be non-negative")
+		v.Check(cfg.cache.backend == "memory" || cfg.cache.backend == "redis", "cache-backend", "must be either memory or redis")
+
+		if cfg.cache.backend == "redis" {
+			v.Check(cfg.cache.redis.addr != "", "cache-redis-addr", "must be provided")
+			v.Check(cfg.cache.redis.db >= 0, "cache-redis-db", "must be non-negative")
+			v.Check(cfg.cache.redis.poolSize > 0, "cache-redis-pool-size", "must be greater than zero")
+			v.Check(cfg.cache.redis.timeout > 0, "cache-redis-timeout", "must be greater than zero")
+		}
+	}
+
+	v.Check(cfg.snapshot.refreshInterval > 0, "snapshot-refresh-interval", "must be greater than zero")
+
+	v.Check(cfg.webhooks.workers >= 0, "webhooks-workers", "must be non-negative")
+	v.Check(cfg.webhooks.timeout > 0, "webhooks-timeout", "must be greater than zero")
+	v.Check(cfg.webhooks.maxAttempts > 0, "webhooks-max-attempts", "must be greater than zero")
+	v.Check(cfg.webhooks.backoff > 0, "webhooks-backoff", "must be greater than zero")
+	v.Check(cfg.webhooks.pollInterval > 0, "webhooks-poll-interval", "must be greater than zero")
+	v.Check(cfg.webhooks.retention > 0, "webhooks-retention", "must be greater than zero")
+}
+
+// redactSettings returns a copy of settings with secrets redacted.
+func redactSettings(settings map[string]string) map[string]string {
+	redacted := make(map[string]string, len(settings))
+
+	for name, value := range settings {
+		if secretSettings[name] {
+			items := splitList(value)
+			for i, item := range items {
+				items[i] = redact(item)
+			}
+			value = strings.Join(items, ",")
+		}
+		redacted[name] = value
+	}
+
+	return redacted
+}
+
+func printConfig(w io.Writer, settings map[string]string) {
+	for _, name := range sortedKeys(settings) {
+		fmt.Fprintf(w, "%s=%s\n", name, settings[name])
+	}
+}
+
+var dsnPasswordRX = regexp.MustCompile(`(\b(?:ssl)?password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// secretQueryParams are the DSN parameters that carry passwords in a URL.
+var secretQueryParams = []string{"password", "sslpassword"}
+
+// redact hides a secret value. A URL keeps everything but its password and
+// the password and sslpassword parameters, a key=value DSN everything but
+// password= and sslpassword=.
+func redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" && strings.HasPrefix(value, u.Scheme+"://") {
+		q := u.Query()
+		for _, key := range secretQueryParams {
+			if q.Has(key) {
+				q.Set(key, "xxxxx")
+				u.RawQuery = q.Encode()
+			}
+		}
+		return u.Redacted()
+	}
+
+	if dsnPasswordRX.MatchString(value) {
+		return dsnPasswordRX.ReplaceAllString(value, "${1}xxxxx")
+	}
+
+	return "xxxxx"
+}
+
+// listValue is a flag holding a comma-separated list. Lists in the config
+// file are joined with commas too.
+type listValue struct {
+	items *[]string
+}
+
+func (l listValue) String() string {
+	if l.items == nil {
+		return ""
+	}
+	return strings.Join(*l.items, ",")
+}
+
+func (l listValue) Set(value string) error {
+	*l.items = splitList(value)
+	return nil
+}
+
+// splitList splits a comma-separated setting, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLegacyEnvAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := os.WriteFile(path, []byte("db:\n  dsn: postgres://file/db\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"file", nil, nil, "postgres://file/db"},
+		{"alias over file", map[string]string{"DB_DSN": "postgres://alias/db"}, nil, "postgres://alias/db"},
+		{"prefixed over alias", map[string]string{"DB_DSN": "postgres://alias/db", "BANNER_DB_DSN": "postgres://env/db"}, nil, "postgres://env/db"},
+		{"flag over alias", map[string]string{"DB_DSN": "postgres://alias/db"}, []string{"-db-dsn", "postgres://flag/db"}, "postgres://flag/db"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_DSN", "BANNER_DB_DSN"} {
+				t.Setenv(key, "")
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			args := append([]string{"-config", path}, tt.args...)
+
+			cfg, settings, _, err := parseConfig(args, flag.ContinueOnError, io.Discard)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if cfg.db.dsn != tt.want {
+				t.Errorf("db.dsn = %q, want %q", cfg.db.dsn, tt.want)
+			}
+			if settings["db-dsn"] != tt.want {
+				t.Errorf("settings[db-dsn] = %q, want %q", settings["db-dsn"], tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"url password", "postgres://banner:secret@db/banner", "postgres://banner:xxxxx@db/banner"},
+		{"url without password", "postgres://banner@db/banner?sslmode=disable", "postgres://banner@db/banner?sslmode=disable"},
+		{"url password param", "postgres://db/banner?password=secret&user=banner", "postgres://db/banner?password=xxxxx&user=banner"},
+		{"url sslpassword param", "postgres://banner@db/banner?sslmode=verify-full&sslpassword=secret", "postgres://banner@db/banner?sslmode=verify-full&sslpassword=xxxxx"},
+		{"url without host", "postgres:///banner?host=/tmp&password=secret", "postgres:///banner?host=%2Ftmp&password=xxxxx"},
+		{"key value", "host=db user=banner password=secret dbname=banner", "host=db user=banner password=xxxxx dbname=banner"},
+		{"key value quoted", `host=db password='it\'s secret' dbname=banner`, "host=db password=xxxxx dbname=banner"},
+		{"key value spaces", "host=db password = secret", "host=db password = xxxxx"},
+		{"key value sslpassword", "host=db sslpassword=secret sslkey=/etc/key", "host=db sslpassword=xxxxx sslkey=/etc/key"},
+		{"plain secret", "hunter2", "xxxxx"},
+		{"plain secret with colon", "kid:HS256:c2VjcmV0", "xxxxx"},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.value); got != tt.want {
+				t.Errorf("redact(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/testdb"
+)
+
+// contractServer sends requests through routes() and checks every response
+// against api.yaml.
+type contractServer struct {
+	t       *testing.T
+	handler http.Handler
+}
+
+func newContractServer(t *testing.T, app *application) *contractServer {
+	return &contractServer{t: t, handler: app.routes()}
+}
+
+// signedTokenArgs runs the application with signed access tokens, which are
+// verified without the database.
+func signedTokenArgs() []string {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), 32))
+	return []string{"-token-mode", "signed", "-token-keys", "test:HS256:" + key}
+}
+
+// do sends a request with an optional bearer token, body and header pairs.
+func (s *contractServer) do(method, path, token, body string, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	return s.doContext(context.Background(), method, path, token, body, header...)
+}
+
+func (s *contractServer) doContext(ctx context.Context, method, path, token, body string, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	r := httptest.NewRequest(method, path, strings.NewReader(body)).WithContext(ctx)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	res := httptest.NewRecorder()
+	s.handler.ServeHTTP(res, r)
+
+	checkResponse(s.t, r, res)
+
+	return res
+}
+
+// expect sends a request and fails the test unless it gets status.
+func (s *contractServer) expect(status int, method, path, token, body string, header ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	res := s.do(method, path, token, body, header...)
+	if res.Code != status {
+		s.t.Errorf("%s %s: status %d, want %d: %s", method, path, res.Code, status, res.Body)
+	}
+
+	return res
+}
+
+func decodeBody(t *testing.T, res *httptest.ResponseRecorder, dst any) {
+	t.Helper()
+
+	if err := json.Unmarshal(res.Body.Bytes(), dst); err != nil {
+		t.Fatalf("decoding %s: %v", res.Body, err)
+	}
+}
+
+func TestRoutesContractWithoutDatabase(t *testing.T) {
+	app := newTestApplication(t, nil, signedTokenArgs()...)
+	s := newContractServer(t, app)
+
+	admin, err := app.accessTokens.New(&data.User{UserID: 1, Role: data.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := app.accessTokens.New(&data.User{UserID: 2, Role: data.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The access token a client still holds when it logs in again or
+	// refreshes.
+	key, err := data.ParseSigningKey(signedTokenArgs()[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredTokens, err := data.NewAccessTokens(-time.Minute, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := expiredTokens.New(&data.User{UserID: 2, Role: data.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	adminRoutes := []struct{ method, path string }{
+		{http.MethodGet, "/banner"},
+		{http.MethodPost, "/banner"},
+		{http.MethodGet, "/banner/export"},
+		{http.MethodGet, "/banner/events"},
+		{http.MethodGet, "/banner/trash"},
+		{http.MethodPost, "/banner/import"},
+		{http.MethodPost, "/banner/1/restore"},
+		{http.MethodPatch, "/banner/1"},
+		{http.MethodDelete, "/banner/1"},
+		{http.MethodGet, "/webhooks"},
+		{http.MethodPost, "/webhooks"},
+		{http.MethodDelete, "/webhooks/1"},
+		{http.MethodGet, "/audit"},
+		{http.MethodGet, "/admin/config"},
+		{http.MethodGet, "/debug/vars"},
+		{http.MethodGet, "/users"},
+		{http.MethodGet, "/users/1"},
+		{http.MethodPatch, "/users/1"},
+		{http.MethodDelete, "/users/1"},
+	}
+
+	for _, route := range adminRoutes {
+		s.expect(http.StatusUnauthorized, route.method, route.path, "", "")
+		s.expect(http.StatusForbidden, route.method, route.path, user.Plaintext, "")
+		s.expect(http.StatusUnauthorized, route.method, route.path, "not-a-token", "")
+	}
+
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/user_banner?tag_id=1&feature_id=1"},
+		{http.MethodPatch, "/me"},
+		{http.MethodDelete, "/token"},
+	} {
+		s.expect(http.StatusUnauthorized, route.method, route.path, "", "")
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"healthz", http.MethodGet, "/healthz", "", "", http.StatusOK},
+		{"readyz", http.MethodGet, "/readyz", "", "", http.StatusOK},
+		{"openapi", http.MethodGet, "/openapi.yaml", "", "", http.StatusOK},
+		{"config", http.MethodGet, "/admin/config", admin.Plaintext, "", http.StatusOK},
+		{"debug vars", http.MethodGet, "/debug/vars", admin.Plaintext, "", http.StatusOK},
+
+		{"user banner without filters", http.MethodGet, "/user_banner", user.Plaintext, "", http.StatusUnprocessableEntity},
+		{"user banner with a bad tag", http.MethodGet, "/user_banner?tag_id=x&feature_id=1", user.Plaintext, "", http.StatusUnprocessableEntity},
+		{"banners with a bad limit", http.MethodGet, "/banner?limit=-1", admin.Plaintext, "", http.StatusUnprocessableEntity},
+		{"create banner with bad JSON", http.MethodPost, "/banner", admin.Plaintext, "{", http.StatusBadRequest},
+		{"create invalid banner", http.MethodPost, "/banner", admin.Plaintext, "{}", http.StatusUnprocessableEntity},
+		{"export with a bad format", http.MethodGet, "/banner/export?format=xml", admin.Plaintext, "", http.StatusUnprocessableEntity},
+		{"events with a bad id", http.MethodGet, "/banner/events?last_event_id=-1", admin.Plaintext, "", http.StatusUnprocessableEntity},
+		{"trash with a bad offset", http.MethodGet, "/banner/trash?offset=-1", admin.Plaintext, "", http.StatusUnprocessableEntity},
+		{"import nothing", http.MethodPost, "/banner/import", admin.Plaintext, "", http.StatusBadRequest},
+		{"import an invalid line", http.MethodPost, "/banner/import", admin.Plaintext, `{"feature_id":1}`, http.StatusUnprocessableEntity},
+		{"import dry run", http.MethodPost, "/banner/import?dry_run=true", admin.Plaintext,
+			`{"feature_id":1,"tag_ids":[1],"content":{"title":"t","text":"x","url":"https://example.com"}}`, http.StatusOK},
+		{"restore a bad id", http.MethodPost, "/banner/x/restore", admin.Plaintext, "", http.StatusNotFound},
+		{"update a bad id", http.MethodPatch, "/banner/x", admin.Plaintext, "{}", http.StatusNotFound},
+		{"delete a bad id", http.MethodDelete, "/banner/0", admin.Plaintext, "", http.StatusNotFound},
+		{"create invalid webhook", http.MethodPost, "/webhooks", admin.Plaintext, `{"url":"ftp://x"}`, http.StatusUnprocessableEntity},
+		{"delete a bad webhook id", http.MethodDelete, "/webhooks/x", admin.Plaintext, "", http.StatusNotFound},
+		{"audit with a bad range", http.MethodGet, "/audit?from=yesterday", admin.Plaintext, "", http.StatusUnprocessableEntity},
+		{"users with a bad role", http.MethodGet, "/users?role=root", admin.Plaintext, "", http.StatusUnprocessableEntity},
+		{"show a bad user id", http.MethodGet, "/users/x", admin.Plaintext, "", http.StatusNotFound},
+		{"update a bad user id", http.MethodPatch, "/users/x", admin.Plaintext, "{}", http.StatusNotFound},
+		{"delete a bad user id", http.MethodDelete, "/users/0", admin.Plaintext, "", http.StatusNotFound},
+		{"register with bad JSON", http.MethodPost, "/user", "", "[]", http.StatusBadRequest},
+		{"register with a short password", http.MethodPost, "/user", "", `{"username":"someone","role":"user","password":"short"}`, http.StatusUnprocessableEntity},
+		{"change password without the current one", http.MethodPatch, "/me", user.Plaintext, `{"password":"correct-horse-battery"}`, http.StatusUnprocessableEntity},
+		{"login with bad JSON", http.MethodPost, "/token", "", "{", http.StatusBadRequest},
+		{"login without credentials", http.MethodPost, "/token", "", "{}", http.StatusUnprocessableEntity},
+		{"refresh with bad JSON", http.MethodPost, "/token/refresh", "", "{", http.StatusBadRequest},
+		{"refresh without a token", http.MethodPost, "/token/refresh", "", "{}", http.StatusUnprocessableEntity},
+		{"login with an expired bearer", http.MethodPost, "/token", expired.Plaintext, "{}", http.StatusUnprocessableEntity},
+		{"login with a bad bearer", http.MethodPost, "/token", "not-a-token", "{}", http.StatusUnprocessableEntity},
+		{"refresh with an expired bearer", http.MethodPost, "/token/refresh", expired.Plaintext, "{}", http.StatusUnprocessableEntity},
+		{"expired bearer elsewhere", http.MethodPatch, "/me", expired.Plaintext, "{}", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newContractServer(t, app)
+
+			s.expect(tt.status, tt.method, tt.path, tt.token, tt.body)
+
+			if tt.status >= 400 {
+				s.expect(tt.status, tt.method, tt.path, tt.token, tt.body, "Accept", problemContentType)
+			}
+		})
+	}
+
+	t.Run("events stream", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		res := s.doContext(ctx, http.MethodGet, "/banner/events", admin.Plaintext, "")
+		if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "text/event-stream" {
+			t.Errorf("status %d, Content-Type %q", res.Code, res.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("unknown route", func(t *testing.T) {
+		for _, accept := range []string{"", problemContentType} {
+			res := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+			r.Header.Set("Accept", accept)
+			app.routes().ServeHTTP(res, r)
+
+			if res.Code != http.StatusNotFound {
+				t.Errorf("status %d, want 404", res.Code)
+			}
+			checkErrorResponse(t, res)
+
+			res = httptest.NewRecorder()
+			r = httptest.NewRequest(http.MethodPut, "/banner", nil)
+			r.Header.Set("Accept", accept)
+			app.routes().ServeHTTP(res, r)
+
+			if res.Code != http.StatusMethodNotAllowed {
+				t.Errorf("status %d, want 405", res.Code)
+			}
+			checkErrorResponse(t, res)
+		}
+	})
+}
+
+func TestRoutesContractWithDatabase(t *testing.T) {
+	db := testdb.Open(t)
+
+	app := newTestApplication(t, db, signedTokenArgs()...)
+	s := newContractServer(t, app)
+
+	const password = "correct-horse-battery"
+
+	var registered struct {
+		User         data.User `json:"user"`
+		Token        string    `json:"token"`
+		RefreshToken string    `json:"refresh_token"`
+	}
+
+	res := s.expect(http.StatusCreated, http.MethodPost, "/user", "",
+		`{"username":"contract_admin","role":"admin","password":"`+password+`"}`)
+	decodeBody(t, res, &registered)
+	adminID := registered.User.UserID
+
+	s.expect(http.StatusUnprocessableEntity, http.MethodPost, "/user", "",
+		`{"username":"contract_admin","role":"admin","password":"`+password+`"}`)
+
+	res = s.expect(http.StatusCreated, http.MethodPost, "/user", "",
+		`{"username":"contract_user","role":"user","password":"`+password+`"}`)
+	decodeBody(t, res, &registered)
+	userID, user := registered.User.UserID, registered.Token
+
+	var login struct {
+		NewToken     data.AccessToken `json:"new_token"`
+		RefreshToken data.Token       `json:"refresh_token"`
+	}
+
+	res = s.expect(http.StatusCreated, http.MethodPost, "/token", "",
+		`{"username":"contract_admin","password":"`+password+`"}`)
+	decodeBody(t, res, &login)
+	admin := login.NewToken.Plaintext
+
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token", "",
+		`{"username":"contract_admin","password":"wrong-password"}`)
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token", "",
+		`{"username":"nobody_here","password":"`+password+`"}`)
+
+	s.expect(http.StatusCreated, http.MethodPost, "/token/refresh", "",
+		`{"refresh_token":"`+login.RefreshToken.Plaintext+`"}`)
+	s.expect(http.StatusUnauthorized, http.MethodPost, "/token/refresh", "",
+		`{"refresh_token":"AAAAAAAAAAAAAAAAAAAAAAAAAA"}`)
+
+	banner := `{"feature_id":7,"tag_ids":[1,2],"content":{"title":"t","text":"x","url":"https://example.com"},"is_active":true}`
+
+	var created struct {
+		BannerID int64 `json:"banner_id"`
+	}
+
+	res = s.expect(http.StatusCreated, http.MethodPost, "/banner", admin, banner)
+	decodeBody(t, res, &created)
+	bannerPath := fmt.Sprintf("/banner/%d", created.BannerID)
+
+	s.expect(http.StatusOK, http.MethodGet, "/user_banner?tag_id=1&feature_id=7", user, "")
+	s.expect(http.StatusOK, http.MethodGet, "/user_banner?tag_id=2&feature_id=7&use_last_revision=true", user, "")
+	s.expect(http.StatusNotFound, http.MethodGet, "/user_banner?tag_id=3&feature_id=7", user, "")
+
+	s.expect(http.StatusOK, http.MethodGet, "/banner", admin, "")
+	s.expect(http.StatusOK, http.MethodGet, "/banner?feature_id=7&tag_id=1&limit=1", admin, "")
+	s.expect(http.StatusOK, http.MethodGet, "/banner?feature_id=999", admin, "")
+
+	s.expect(http.StatusOK, http.MethodPatch, bannerPath, admin, `{"is_active":false}`)
+	s.expect(http.StatusNotFound, http.MethodPatch, "/banner/999999", admin, `{"is_active":false}`)
+	s.expect(http.StatusForbidden, http.MethodGet, "/user_banner?tag_id=1&feature_id=7&use_last_revision=true", user, "")
+
+	s.expect(http.StatusOK, http.MethodGet, "/banner/export", admin, "")
+	s.expect(http.StatusOK, http.MethodGet, "/banner/export?format=csv&feature_id=7", admin, "")
+	s.expect(http.StatusCreated, http.MethodPost, "/banner/import", admin,
+		`{"feature_id":8,"tag_ids":[1],"content":{"title":"t","text":"x","url":"https://example.com"}}`)
+
+	s.expect(http.StatusNoContent, http.MethodDelete, bannerPath, admin, "")
+	s.expect(http.StatusNotFound, http.MethodDelete, bannerPath, admin, "")
+	s.expect(http.StatusOK, http.MethodGet, "/banner/trash", admin, "")
+	s.expect(http.StatusOK, http.MethodPost, bannerPath+"/restore", admin, "")
+	s.expect(http.StatusNotFound, http.MethodPost, "/banner/999999/restore", admin, "")
+
+	s.expect(http.StatusOK, http.MethodGet, "/audit", admin, "")
+	s.expect(http.StatusOK, http.MethodGet, fmt.Sprintf("/audit?target_type=banner&target_id=%d", created.BannerID), admin, "")
+
+	var webhook struct {
+		Webhook data.Webhook `json:"webhook"`
+	}
+
+	res = s.expect(http.StatusCreated, http.MethodPost, "/webhooks", admin, `{"url":"https://example.com/hook","feature_ids":[7]}`)
+	decodeBody(t, res, &webhook)
+	webhookPath := fmt.Sprintf("/webhooks/%d", webhook.Webhook.WebhookID)
+
+	s.expect(http.StatusOK, http.MethodGet, "/webhooks", admin, "")
+	s.expect(http.StatusNoContent, http.MethodDelete, webhookPath, admin, "")
+	s.expect(http.StatusNotFound, http.MethodDelete, webhookPath, admin, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	res = s.doContext(ctx, http.MethodGet, "/banner/events?last_event_id=0", admin, "")
+	if res.Code != http.StatusOK || !strings.Contains(res.Body.String(), "event: ") {
+		t.Errorf("GET /banner/events: status %d, body %q", res.Code, res.Body)
+	}
+
+	userPath := fmt.Sprintf("/users/%d", userID)
+
+	s.expect(http.StatusOK, http.MethodGet, "/users", admin, "")
+	s.expect(http.StatusOK, http.MethodGet, "/users?role=admin&limit=1", admin, "")
+	s.expect(http.StatusOK, http.MethodGet, userPath, admin, "")
+	s.expect(http.StatusNotFound, http.MethodGet, "/users/999999", admin, "")
+	s.expect(http.StatusOK, http.MethodPatch, userPath, admin, `{"username":"contract_user_2"}`)
+	s.expect(http.StatusUnprocessableEntity, http.MethodPatch, userPath, admin, `{"username":"contract_admin"}`)
+	s.expect(http.StatusConflict, http.MethodPatch, fmt.Sprintf("/users/%d", adminID), admin, `{"role":"user"}`)
+
+	s.expect(http.StatusUnprocessableEntity, http.MethodPatch, "/me", user,
+		`{"current_password":"wrong-password","password":"another-horse-battery"}`)
+	res = s.expect(http.StatusOK, http.MethodPatch, "/me", user,
+		`{"current_password":"`+password+`","password":"another-horse-battery"}`)
+	decodeBody(t, res, &registered)
+
+	s.expect(http.StatusNoContent, http.MethodDelete, "/token", registered.Token, "")
+
+	s.expect(http.StatusNoContent, http.MethodDelete, userPath, admin, "")
+	s.expect(http.StatusNotFound, http.MethodDelete, userPath, admin, "")
+	s.expect(http.StatusConflict, http.MethodDelete, fmt.Sprintf("/users/%d", adminID), admin, "")
+}
+
+// TestRevocationNotifications checks that a revocation made on another
+// instance, as sent on the token_revocations channel, rejects earlier tokens.
+func TestRevocationNotifications(t *testing.T) {
+	app := newTestApplication(t, nil, signedTokenArgs()...)
+	s := newContractServer(t, app)
+
+	admin, err := app.accessTokens.New(&data.User{UserID: 1, Role: data.RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.expect(http.StatusOK, http.MethodGet, "/admin/config", admin.Plaintext, "")
+
+	app.applyRevocation("not a revocation")
+	app.applyRevocation(fmt.Sprintf("2 %d", time.Now().Add(time.Second).Unix()))
+
+	s.expect(http.StatusOK, http.MethodGet, "/admin/config", admin.Plaintext, "")
+
+	app.applyRevocation(fmt.Sprintf("1 %d", time.Now().Add(time.Second).Unix()))
+
+	s.expect(http.StatusUnauthorized, http.MethodGet, "/admin/config", admin.Plaintext, "")
+}
@@ -1,23 +1,114 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// Stable error codes, returned as the code member of problem+json responses.
+const (
+	codeNotFound               = "not_found"
+	codeBannerNotFound         = "banner_not_found"
+	codeWebhookNotFound        = "webhook_not_found"
+	codeUserNotFound           = "user_not_found"
+	codeMethodNotAllowed       = "method_not_allowed"
+	codeInternalError          = "internal_error"
+	codeBadRequest             = "bad_request"
+	codeFailedValidation       = "failed_validation"
+	codeFailedImport           = "failed_import"
+	codeEditConflict           = "edit_conflict"
+	codeLastAdmin              = "last_admin"
+	codeRateLimited            = "rate_limited"
+	codeLoginLocked            = "login_locked"
+	codeInvalidCredentials     = "invalid_credentials"
+	codeInvalidToken           = "invalid_token"
+	codeAuthenticationRequired = "authentication_required"
+	codeForbidden              = "forbidden"
+	codeClientCertRequired     = "client_certificate_required"
+	codeServiceUnavailable     = "service_unavailable"
+)
+
+const problemContentType = "application/problem+json"
+
 func (app *application) logError(r *http.Request, err error) {
 	var (
-		method = r.Method
-		uri    = r.URL.RequestURI()
+		method    = r.Method
+		uri       = r.URL.RequestURI()
+		requestID = app.contextGetRequestID(r)
 	)
 
-	app.logger.Error(err.Error(), "method", method, "uri", uri)
+	if requestCanceled(r) {
+		app.logger.Warn("request canceled", "error", err.Error(), "method", method, "uri", uri, "request_id", requestID)
+		return
+	}
+
+	app.logger.Error(err.Error(), "method", method, "uri", uri, "request_id", requestID)
+}
+
+// requestCanceled reports whether the client went away, which cancels the
+// request's queries without anything having failed.
+func requestCanceled(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
+
+// wantsProblem reports whether the client asked for problem+json. Other
+// clients keep getting the {"error": ...} envelope.
+func wantsProblem(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == problemContentType {
+			return true
+		}
+	}
+	return false
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message any) {
-	env := envelope{"error": message}
+// errorResponse writes message, which is a string, a map of field errors or
+// an import report, in the format the client negotiated.
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code string, message any) {
+	if !wantsProblem(r) {
+		err := app.writeJSON(w, status, envelope{"error": message}, nil)
+		if err != nil {
+			app.logError(r, err)
+			w.WriteHeader(500)
+		}
+		return
+	}
+
+	// An RFC 7807 body, with code as a stable, machine-readable extension.
+	problem := envelope{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"code":   code,
+	}
+
+	if requestID := app.contextGetRequestID(r); requestID != "" {
+		problem["instance"] = requestID
+	}
 
-	err := app.writeJSON(w, status, env, nil)
+	switch message := message.(type) {
+	case string:
+		problem["detail"] = message
+	case map[string]string:
+		problem["detail"] = "one or more fields failed validation"
+		problem["errors"] = message
+	case []importLineError:
+		problem["detail"] = "one or more lines failed validation"
+		problem["lines"] = message
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", problemContentType)
+
+	err := app.writeJSON(w, status, problem, headers)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
@@ -25,56 +116,107 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the requested resource could not be found"
+	app.errorResponse(w, r, http.StatusNotFound, codeNotFound, message)
+}
+
+func (app *application) bannerNotFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "banner not found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, codeBannerNotFound, message)
+}
+
+func (app *application) webhookNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "webhook not found"
+	app.errorResponse(w, r, http.StatusNotFound, codeWebhookNotFound, message)
+}
+
+func (app *application) userNotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	message := "user not found"
+	app.errorResponse(w, r, http.StatusNotFound, codeUserNotFound, message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	// The breaker logs the outage once instead of every refused request.
+	if databaseUnavailable(err) {
+		app.databaseUnavailableResponse(w, r)
+		return
+	}
+
 	app.logError(r, err)
 
+	if requestCanceled(r) {
+		return
+	}
+
 	message := "internal server error"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, codeInternalError, message)
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.errorResponse(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
 }
 
 func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeFailedValidation, errors)
+}
+
+func (app *application) lastAdminResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the last admin can't be demoted or deleted"
+	app.errorResponse(w, r, http.StatusConflict, codeLastAdmin, message)
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	app.errorResponse(w, r, http.StatusConflict, codeEditConflict, message)
 }
 
 func (app *application) rateLimitExceedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceed"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeRateLimited, message)
+}
+
+func (app *application) loginLockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(max(math.Ceil(retryAfter.Seconds()), 1))))
+
+	message := "too many failed logins, try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeLoginLocked, message)
 }
 
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "user not authorized"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidCredentials, message)
 }
 
 func (app *application) invalidTokenResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid or missing token"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidToken, message)
 }
 
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeAuthenticationRequired, message)
 }
 
 func (app *application) forbiddenAccessResponse(w http.ResponseWriter, r *http.Request) {
 	message := "user does not have access"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeForbidden, message)
+}
+
+func (app *application) clientCertRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "a verified client certificate is required to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, codeClientCertRequired, message)
+}
+
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the server is temporarily unable to handle the request"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, codeServiceUnavailable, message)
+}
+
+func (app *application) failedImportResponse(w http.ResponseWriter, r *http.Request, report []importLineError) {
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeFailedImport, report)
 }
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+const eventsPageSize = 100
+
+// eventsPendingRetry is how soon events held back behind a running
+// transaction are read again, rather than at the next poll.
+const eventsPendingRetry = time.Second
+
+// eventBroker fans banner events out to the connected SSE clients. A client
+// that falls behind is disconnected and expected to resume with Last-Event-ID.
+type eventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan *data.BannerEvent]struct{}
+	closed      bool
+
+	// heldSince is when, in Unix nanoseconds, events started being held
+	// back behind running transactions, or zero.
+	heldSince atomic.Int64
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[chan *data.BannerEvent]struct{})}
+}
+
+func (b *eventBroker) subscribe() chan *data.BannerEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	ch := make(chan *data.BannerEvent, 64)
+	b.subscribers[ch] = struct{}{}
+
+	return ch
+}
+
+func (b *eventBroker) unsubscribe(ch chan *data.BannerEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[ch]; ok {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (b *eventBroker) publish(event *data.BannerEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// setHeld records whether events are held back after the latest read.
+func (b *eventBroker) setHeld(held bool) {
+	if !held {
+		b.heldSince.Store(0)
+		return
+	}
+
+	b.heldSince.CompareAndSwap(0, time.Now().UnixNano())
+}
+
+// heldFor returns how long events have been held back.
+func (b *eventBroker) heldFor() time.Duration {
+	since := b.heldSince.Load()
+	if since == 0 {
+		return 0
+	}
+
+	return time.Since(time.Unix(0, since))
+}
+
+// publishEventMetrics exposes how long the event feed has been held back on
+// /debug/vars.
+func (app *application) publishEventMetrics() {
+	expvar.Publish("events_held_seconds", expvar.Func(func() any {
+		return app.events.heldFor().Seconds()
+	}))
+}
+
+func (b *eventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (app *application) startEventListener(ctx context.Context) {
+	notify := app.listen(ctx, "event listener", data.BannerEventsChannel)
+
+	app.background(func() {
+		var (
+			pos     data.EventPosition
+			started bool
+			pending bool
+		)
+
+		pos, started, pending = app.dispatchEvents(ctx, pos, started)
+
+		poll := time.NewTicker(app.config.events.pollInterval)
+		defer poll.Stop()
+
+		purge := time.NewTicker(time.Hour)
+		defer purge.Stop()
+
+		retry := time.NewTimer(eventsPendingRetry)
+		defer retry.Stop()
+
+		for {
+			if !pending {
+				retry.Stop()
+			} else {
+				retry.Reset(eventsPendingRetry)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+				pos, started, pending = app.dispatchEvents(ctx, pos, started)
+			case <-poll.C:
+				pos, started, pending = app.dispatchEvents(ctx, pos, started)
+			case <-retry.C:
+				pos, started, pending = app.dispatchEvents(ctx, pos, started)
+			case <-purge.C:
+				_, err := app.models.Events.Purge(ctx, app.config.events.retention)
+				if err != nil {
+					app.logger.Error(err.Error(), "task", "event purger")
+				}
+			}
+		}
+	})
+}
+
+// dispatchEvents publishes every event logged after pos and returns the
+// position of the last one published, and whether more events are held back
+// behind running transactions. Until started, it skips the existing log.
+func (app *application) dispatchEvents(ctx context.Context, pos data.EventPosition, started bool) (data.EventPosition, bool, bool) {
+	if !started {
+		latest, err := app.models.Events.Latest(ctx)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "event listener")
+			return pos, false, false
+		}
+		// Events of running transactions may already be held back.
+		return latest, true, true
+	}
+
+	for {
+		events, pending, err := app.models.Events.GetSince(ctx, pos, eventsPageSize, app.config.events.maxHold)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "event listener")
+			return pos, true, false
+		}
+
+		for _, event := range events {
+			app.events.publish(event)
+			pos = event.Position
+		}
+
+		if pending || len(events) < eventsPageSize {
+			app.events.setHeld(pending)
+			return pos, true, pending
+		}
+	}
+}
+
+func (app *application) bannerEventsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	var lastID int64
+
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		v.Check(err == nil && id >= 0, "last_event_id", "must be a non-negative integer")
+		lastID = id
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ch := app.events.subscribe()
+	if ch == nil {
+		app.serviceUnavailableResponse(w, r)
+		return
+	}
+	defer app.events.unsubscribe(ch)
+
+	rc := http.NewResponseController(w)
+
+	// The stream outlives the server's read and write timeouts.
+	if err := clearDeadlines(rc); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The position of the last event sent. Live events up to it were
+	// already replayed from the log.
+	var sent data.EventPosition
+
+	if lastEventID != "" {
+		pos, err := app.models.Events.PositionOf(r.Context(), lastID)
+		if err != nil {
+			app.logError(r, err)
+			return
+		}
+		sent = pos
+
+		for {
+			events, pending, err := app.models.Events.GetSince(r.Context(), sent, eventsPageSize, app.config.events.maxHold)
+			if err != nil {
+				app.logError(r, err)
+				return
+			}
+
+			for _, event := range events {
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+				sent = event.Position
+			}
+
+			// Held back events arrive through the broker.
+			if pending || len(events) < eventsPageSize {
+				break
+			}
+		}
+	}
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if !event.Position.After(sent) {
+				continue
+			}
+
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			sent = event.Position
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event *data.BannerEvent) error {
+	js, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.EventID, event.Type, js)
+	return err
+}
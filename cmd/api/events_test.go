@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventBrokerHeldFor(t *testing.T) {
+	b := newEventBroker()
+
+	if held := b.heldFor(); held != 0 {
+		t.Fatalf("held for %s before any read; want 0", held)
+	}
+
+	b.setHeld(true)
+	time.Sleep(10 * time.Millisecond)
+	b.setHeld(true)
+
+	// A read that is still held back doesn't restart the clock.
+	if held := b.heldFor(); held < 10*time.Millisecond {
+		t.Errorf("held for %s; want at least 10ms", held)
+	}
+
+	b.setHeld(false)
+
+	if held := b.heldFor(); held != 0 {
+		t.Errorf("held for %s after a full read; want 0", held)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/skraio/banner-service/internal/data"
+)
+
+func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"status": "available"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	checks := envelope{}
+	ready := true
+
+	if app.config.snapshot.enabled {
+		loaded := app.snapshot.ready()
+		checks["snapshot"] = loaded
+		ready = ready && loaded
+	}
+
+	// With the breaker open the instance stays ready as long as it has a
+	// snapshot to serve user banners from.
+	if app.config.breaker.enabled {
+		state := app.breaker.State()
+		checks["database"] = state
+		ready = ready && (state != data.BreakerOpen || app.snapshot.ready())
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	err := app.writeJSON(w, status, envelope{"ready": ready, "checks": checks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/skraio/banner-service/internal/data"
@@ -40,7 +41,9 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 	w.Write(js)
 
@@ -137,3 +140,32 @@ func (app *application) readBool(qs url.Values, key string, defaultValue bool, v
 
 	return b
 }
+
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 date-time")
+		return time.Time{}
+	}
+
+	return t
+}
+
+// clearDeadlines lifts the server's read and write timeouts for a streamed
+// response. Writers that don't support deadlines have none to lift.
+func clearDeadlines(rc *http.ResponseController) error {
+	for _, set := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		err := set(time.Time{})
+		if err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+	}
+
+	return nil
+}
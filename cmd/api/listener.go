@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	listenerMinBackoff = time.Second
+	listenerMaxBackoff = time.Minute
+)
+
+// listen keeps a dedicated connection listening on channel and forwards every
+// notification. Notifications sent while the connection is down are lost, so
+// a nil notification is sent when it drops and again once it is back.
+func (app *application) listen(ctx context.Context, task, channel string) <-chan *pgconn.Notification {
+	notify := make(chan *pgconn.Notification, 32)
+
+	send := func(n *pgconn.Notification) bool {
+		select {
+		case notify <- n:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	app.background(func() {
+		backoff := listenerMinBackoff
+		lost := false
+
+		for {
+			conn, err := app.listenConn(ctx, channel)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+
+				app.logger.Error(err.Error(), "task", task)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+
+				backoff = min(2*backoff, listenerMaxBackoff)
+				continue
+			}
+
+			backoff = listenerMinBackoff
+
+			if lost && !send(nil) {
+				conn.Close(context.Background())
+				return
+			}
+
+			for {
+				n, err := conn.WaitForNotification(ctx)
+				if err != nil {
+					conn.Close(context.Background())
+
+					if ctx.Err() != nil {
+						return
+					}
+
+					app.logger.Error(err.Error(), "task", task)
+					break
+				}
+
+				if !send(n) {
+					conn.Close(context.Background())
+					return
+				}
+			}
+
+			lost = true
+			if !send(nil) {
+				return
+			}
+		}
+	})
+
+	return notify
+}
+
+func (app *application) listenConn(ctx context.Context, channel string) (*pgx.Conn, error) {
+	connectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	conn, err := pgx.Connect(connectCtx, app.config.db.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Exec(connectCtx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// loginGuard counts failed logins per username and per client IP. A key that
+// reaches its limit is locked out, for twice as long on every further
+// failure. Counters are forgotten after a success or, by sweep, after the
+// longest lockout has passed without failures.
+//
+// An attempt is counted as a failure when it begins, before the password is
+// checked, so that requests racing through a slow bcrypt comparison can't
+// all slip under the limit. A success or an attempt that ends without a
+// verdict gives its count back.
+type loginGuard struct {
+	maxFailures   int
+	ipMaxFailures int
+	lockout       time.Duration
+	maxLockout    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*loginFailures
+}
+
+type loginFailures struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// loginLockout describes a key that a failure has just locked out.
+type loginLockout struct {
+	key      string
+	failures int
+	duration time.Duration
+}
+
+// loginAttempt is a failure reserved by begin until the attempt settles.
+type loginAttempt struct {
+	username     string
+	reservations []loginReservation
+	lockouts     []loginLockout
+	settled      bool
+}
+
+// loginReservation is what begin did to one counter, so that it can be
+// undone.
+type loginReservation struct {
+	key         string
+	failures    *loginFailures
+	last        time.Time
+	prevLast    time.Time
+	prevUntil   time.Time
+	lockedUntil time.Time
+}
+
+func newLoginGuard(cfg config) *loginGuard {
+	return &loginGuard{
+		maxFailures:   cfg.login.maxFailures,
+		ipMaxFailures: cfg.login.ipMaxFailures,
+		lockout:       cfg.login.lockout,
+		maxLockout:    cfg.login.maxLockout,
+		entries:       make(map[string]*loginFailures),
+	}
+}
+
+func usernameKey(username string) string {
+	return "username:" + username
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// begin starts a login for the username from the ip. While either is locked
+// out it returns how long for and no attempt. Otherwise it counts the
+// attempt as a failure, locking the keys that reach their limit, and
+// returns it to be settled with fail, succeed or release.
+func (g *loginGuard) begin(username, ip string) (*loginAttempt, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+
+	keys := []struct {
+		key   string
+		limit int
+	}{
+		{usernameKey(username), g.maxFailures},
+		{ipKey(ip), g.ipMaxFailures},
+	}
+
+	var remaining time.Duration
+
+	for _, k := range keys {
+		if f, ok := g.entries[k.key]; ok && f.lockedUntil.After(now) {
+			remaining = max(remaining, f.lockedUntil.Sub(now))
+		}
+	}
+
+	if remaining > 0 {
+		return nil, remaining
+	}
+
+	attempt := &loginAttempt{username: username}
+
+	for _, k := range keys {
+		f, ok := g.entries[k.key]
+		if !ok || now.Sub(f.last) > g.maxLockout {
+			f = &loginFailures{}
+			g.entries[k.key] = f
+		}
+
+		res := loginReservation{key: k.key, failures: f, last: now, prevLast: f.last, prevUntil: f.lockedUntil}
+
+		f.count++
+		f.last = now
+
+		if f.count >= k.limit {
+			duration := g.maxLockout
+			if shift := f.count - k.limit; shift < 32 {
+				duration = min(g.lockout<<shift, g.maxLockout)
+			}
+
+			f.lockedUntil = now.Add(duration)
+			res.lockedUntil = f.lockedUntil
+
+			attempt.lockouts = append(attempt.lockouts, loginLockout{key: k.key, failures: f.count, duration: duration})
+		}
+
+		attempt.reservations = append(attempt.reservations, res)
+	}
+
+	return attempt, 0
+}
+
+// fail keeps the failure that begin counted and returns the keys it locked
+// out.
+func (g *loginGuard) fail(a *loginAttempt) []loginLockout {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	a.settled = true
+
+	return a.lockouts
+}
+
+// succeed forgets the failures of the username. The IP only gets its
+// reserved count back, so that logging into one account doesn't reset
+// guessing at others.
+func (g *loginGuard) succeed(a *loginAttempt) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if a.settled {
+		return
+	}
+	a.settled = true
+
+	g.rollback(a)
+	delete(g.entries, usernameKey(a.username))
+}
+
+// release gives back the count of an attempt that ended without checking
+// the password, such as on a database error. It does nothing once the
+// attempt is settled, so it can be deferred.
+func (g *loginGuard) release(a *loginAttempt) {
+	if a == nil {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if a.settled {
+		return
+	}
+	a.settled = true
+
+	g.rollback(a)
+}
+
+func (g *loginGuard) rollback(a *loginAttempt) {
+	for _, res := range a.reservations {
+		f := res.failures
+
+		// The counter was reset or swept since; there is nothing to undo.
+		if g.entries[res.key] != f {
+			continue
+		}
+
+		f.count--
+
+		if f.count <= 0 {
+			delete(g.entries, res.key)
+			continue
+		}
+
+		// Undo only what a later attempt hasn't moved on since.
+		if !res.lockedUntil.IsZero() && f.lockedUntil.Equal(res.lockedUntil) {
+			f.lockedUntil = res.prevUntil
+		}
+		if f.last.Equal(res.last) {
+			f.last = res.prevLast
+		}
+	}
+}
+
+// sweep forgets counters that have been idle for longer than the longest
+// lockout.
+func (g *loginGuard) sweep() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+
+	for key, f := range g.entries {
+		if now.Sub(f.last) > g.maxLockout && !f.lockedUntil.After(now) {
+			delete(g.entries, key)
+		}
+	}
+}
+
+// loginFailed records a failed login or password check and logs the
+// lockouts it causes.
+func (app *application) loginFailed(r *http.Request, attempt *loginAttempt) {
+	app.logger.Info("login failed", "username", attempt.username, "ip", clientIP(r), "request_id", app.contextGetRequestID(r))
+
+	for _, lockout := range app.logins.fail(attempt) {
+		app.logger.Warn("login locked out", "key", lockout.key, "failures", lockout.failures, "duration", lockout.duration, "request_id", app.contextGetRequestID(r))
+	}
+}
+
+// clientIP returns the address of the peer. Requests through a proxy all
+// count towards the proxy's address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func testLoginGuard() *loginGuard {
+	return &loginGuard{
+		maxFailures:   3,
+		ipMaxFailures: 10,
+		lockout:       time.Minute,
+		maxLockout:    time.Hour,
+		entries:       make(map[string]*loginFailures),
+	}
+}
+
+func TestLoginGuardLocksOut(t *testing.T) {
+	g := testLoginGuard()
+
+	for i := 1; i <= 3; i++ {
+		attempt, retryAfter := g.begin("alice", "192.0.2.1")
+		if retryAfter > 0 {
+			t.Fatalf("attempt %d: locked out early", i)
+		}
+
+		lockouts := g.fail(attempt)
+		if i < 3 && len(lockouts) > 0 {
+			t.Fatalf("attempt %d: locked out %v", i, lockouts)
+		}
+		if i == 3 && (len(lockouts) != 1 || lockouts[0].key != usernameKey("alice") || lockouts[0].duration != time.Minute) {
+			t.Fatalf("attempt %d: got lockouts %v; want alice for a minute", i, lockouts)
+		}
+	}
+
+	if _, retryAfter := g.begin("alice", "192.0.2.2"); retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("got retry after %s; want up to a minute", retryAfter)
+	}
+
+	if _, retryAfter := g.begin("bob", "192.0.2.1"); retryAfter > 0 {
+		t.Errorf("bob is locked out by alice's failures")
+	}
+}
+
+func TestLoginGuardDoublesLockout(t *testing.T) {
+	g := testLoginGuard()
+
+	for i := 0; i < 3; i++ {
+		attempt, _ := g.begin("alice", "192.0.2.1")
+		g.fail(attempt)
+	}
+
+	// Let the first lockout pass without waiting for it.
+	g.entries[usernameKey("alice")].lockedUntil = time.Now()
+
+	attempt, retryAfter := g.begin("alice", "192.0.2.1")
+	if retryAfter > 0 {
+		t.Fatal("still locked out")
+	}
+
+	lockouts := g.fail(attempt)
+	if len(lockouts) != 1 || lockouts[0].duration != 2*time.Minute {
+		t.Errorf("got lockouts %v; want alice for two minutes", lockouts)
+	}
+}
+
+// TestLoginGuardConcurrentAttempts starts more attempts than the limit
+// before any of them settles, as requests waiting on bcrypt would.
+func TestLoginGuardConcurrentAttempts(t *testing.T) {
+	g := testLoginGuard()
+
+	var (
+		mu       sync.Mutex
+		attempts []*loginAttempt
+		wg       sync.WaitGroup
+	)
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if attempt, retryAfter := g.begin("alice", "192.0.2.1"); retryAfter == 0 {
+				mu.Lock()
+				attempts = append(attempts, attempt)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if len(attempts) != 3 {
+		t.Fatalf("%d attempts got through; want 3", len(attempts))
+	}
+
+	for _, attempt := range attempts {
+		g.fail(attempt)
+	}
+
+	if _, retryAfter := g.begin("alice", "192.0.2.1"); retryAfter == 0 {
+		t.Error("not locked out after the limit")
+	}
+}
+
+func TestLoginGuardSucceed(t *testing.T) {
+	g := testLoginGuard()
+
+	for i := 0; i < 2; i++ {
+		attempt, _ := g.begin("alice", "192.0.2.1")
+		g.fail(attempt)
+	}
+
+	// The third attempt locks alice out until it settles.
+	attempt, _ := g.begin("alice", "192.0.2.1")
+
+	if _, retryAfter := g.begin("alice", "192.0.2.1"); retryAfter == 0 {
+		t.Fatal("a concurrent attempt got past the limit")
+	}
+
+	g.succeed(attempt)
+
+	if _, ok := g.entries[usernameKey("alice")]; ok {
+		t.Error("alice's failures weren't forgotten")
+	}
+
+	// The IP keeps the two failures but not the successful attempt.
+	if f := g.entries[ipKey("192.0.2.1")]; f == nil || f.count != 2 {
+		t.Errorf("got IP counter %+v; want 2 failures", f)
+	}
+
+	// Settling twice changes nothing.
+	g.release(attempt)
+	g.succeed(attempt)
+
+	if f := g.entries[ipKey("192.0.2.1")]; f == nil || f.count != 2 {
+		t.Errorf("got IP counter %+v after settling again; want 2 failures", f)
+	}
+}
+
+func TestLoginGuardRelease(t *testing.T) {
+	g := testLoginGuard()
+
+	g.release(nil)
+
+	attempt, _ := g.begin("alice", "192.0.2.1")
+	g.release(attempt)
+
+	if len(g.entries) != 0 {
+		t.Errorf("got %d counters after release; want none", len(g.entries))
+	}
+
+	// A released attempt that crossed the limit lifts its lockout.
+	for i := 0; i < 2; i++ {
+		attempt, _ := g.begin("alice", "192.0.2.1")
+		g.fail(attempt)
+	}
+
+	crossing, _ := g.begin("alice", "192.0.2.1")
+	g.release(crossing)
+
+	if f := g.entries[usernameKey("alice")]; f == nil || f.count != 2 || !f.lockedUntil.IsZero() {
+		t.Errorf("got counter %+v after release; want 2 failures and no lockout", f)
+	}
+}
+
+// TestLoginGuardReleaseKeepsLaterLockout releases an attempt after another
+// attempt has locked the shared IP out. That lockout stays.
+func TestLoginGuardReleaseKeepsLaterLockout(t *testing.T) {
+	g := testLoginGuard()
+	g.ipMaxFailures = 2
+
+	first, _ := g.begin("alice", "192.0.2.1")
+
+	second, _ := g.begin("bob", "192.0.2.1")
+	if lockouts := g.fail(second); len(lockouts) != 1 || lockouts[0].key != ipKey("192.0.2.1") {
+		t.Fatalf("got lockouts %v; want the IP", lockouts)
+	}
+
+	g.release(first)
+
+	if _, retryAfter := g.begin("carol", "192.0.2.1"); retryAfter == 0 {
+		t.Error("releasing an earlier attempt lifted the IP lockout")
+	}
+}
+
+func TestLoginGuardSweep(t *testing.T) {
+	g := testLoginGuard()
+
+	for _, name := range []string{"alice", "bob"} {
+		attempt, _ := g.begin(name, "192.0.2.1")
+		g.fail(attempt)
+	}
+
+	g.entries[usernameKey("alice")].last = time.Now().Add(-2 * time.Hour)
+
+	g.sweep()
+
+	if _, ok := g.entries[usernameKey("alice")]; ok {
+		t.Error("idle counter wasn't swept")
+	}
+	if _, ok := g.entries[usernameKey("bob")]; !ok {
+		t.Error("recent counter was swept")
+	}
+}
@@ -3,57 +3,86 @@ package main
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/skraio/banner-service/internal/cache"
 	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
 )
 
-type config struct {
-	port int
-	db   struct {
-		dsn          string
-		maxOpenConns int
-		maxIdleConns int
-		maxIdleTime  time.Duration
-	}
-	limiter struct {
-		rps     float64
-		burst   int
-		enabled bool
-	}
-}
-
 type application struct {
-	config config
-	logger *slog.Logger
-	models data.Models
-	wg     sync.WaitGroup
+	config         config
+	live           atomic.Pointer[liveConfig]
+	logger         *slog.Logger
+	logLevel       *slog.LevelVar
+	limiter        *rate.Limiter
+	models         data.Models
+	replicas       *data.ReplicaSet
+	breaker        *data.Breaker
+	tokens         *tokenCache
+	accessTokens   *data.AccessTokens
+	logins         *loginGuard
+	passwords      data.PasswordPolicy
+	staleResponses expvar.Int
+	cache          cache.Cache
+	bannerGroup    singleflight.Group
+	cacheLoads     *cacheLoads
+	snapshot       *bannerSnapshot
+	events         *eventBroker
+	wg             sync.WaitGroup
 }
 
 func main() {
-	var cfg config
+	cfg, settings, src, err := parseConfig(os.Args[1:], flag.ExitOnError, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	v := validator.New()
+
+	if validateConfig(v, cfg); !v.Valid() {
+		for _, key := range sortedKeys(v.Errors) {
+			fmt.Fprintf(os.Stderr, "%s %s\n", key, v.Errors[key])
+		}
+		os.Exit(1)
+	}
 
-	flag.IntVar(&cfg.port, "port", 8080, "API server port")
-	flag.StringVar(&cfg.db.dsn, "db-dsn", os.Getenv("DB_DSN"), "PostgreSQL DSN")
+	if src.check {
+		printConfig(os.Stdout, redactSettings(settings))
+		return
+	}
 
-	flag.IntVar(&cfg.db.maxOpenConns, "db-max-open-conns", 1200, "PostgreSQL max open connections")
-	flag.IntVar(&cfg.db.maxIdleConns, "db-max-idle-conns", 600, "PostgreSQL max idle connections")
-	flag.DurationVar(&cfg.db.maxIdleTime, "db-max-idle-time", 10*time.Minute, "PostgreSQL max connection idle time")
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.logLevel)
 
-	flag.Float64Var(&cfg.limiter.rps, "limiter-rps", 1000, "Rate limiter maximum requests per second")
-	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 1000, "Rate limiter maximum burst")
-	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
 
-	flag.Parse()
+	logger.Info("config loaded", "file", src.file, "settings", redactSettings(settings))
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+	var breaker *data.Breaker
+	if cfg.breaker.enabled {
+		breaker = data.NewBreaker(cfg.breaker.failures, cfg.breaker.cooldown, func(state data.BreakerState) {
+			if state == data.BreakerOpen {
+				logger.Error("database unavailable, breaker opened", "cooldown", cfg.breaker.cooldown)
+			} else {
+				logger.Info("database available again, breaker closed")
+			}
+		})
+	}
 
-	db, err := openDB(cfg)
+	db, err := openDB(cfg, breaker)
 	if err != nil {
 		logger.Error(err.Error())
 		os.Exit(1)
@@ -62,12 +91,60 @@ func main() {
 
 	logger.Info("database connetion pool established")
 
+	replicas, err := openReplicas(cfg, db)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	defer replicas.Close()
+
+	if replicas.Len() > 0 {
+		logger.Info("read replicas configured", "count", replicas.Len())
+	}
+
+	passwords, err := loadPasswordPolicy(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	accessTokens, err := loadAccessTokens(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	bannerCache, err := openCache(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// The memory cache holds no connections, the redis one has a pool.
+	if c, ok := bannerCache.(interface{ Close() error }); ok {
+		defer c.Close()
+	}
+
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
+		config:       cfg,
+		logger:       logger,
+		logLevel:     logLevel,
+		limiter:      rate.NewLimiter(rate.Limit(cfg.limiter.rps), cfg.limiter.burst),
+		models:       data.NewModels(db, replicas, cfg.db.timeouts),
+		replicas:     replicas,
+		breaker:      breaker,
+		tokens:       newTokenCache(cfg.breaker.tokenTTL),
+		accessTokens: accessTokens,
+		logins:       newLoginGuard(cfg),
+		passwords:    passwords,
+		cache:        bannerCache,
+		cacheLoads:   newCacheLoads(),
+		snapshot:     newBannerSnapshot(),
+		events:       newEventBroker(),
 	}
 
+	app.live.Store(&liveConfig{config: cfg, settings: settings, source: src, loadedAt: time.Now()})
+
 	err = app.serve()
 	if err != nil {
 		logger.Error(err.Error())
@@ -75,12 +152,21 @@ func main() {
 	}
 }
 
-func openDB(cfg config) (*sql.DB, error) {
-	db, err := sql.Open("postgres", cfg.db.dsn)
+// openDB opens the primary pool. With a breaker, every new connection goes
+// through it.
+func openDB(cfg config, breaker *data.Breaker) (*sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(cfg.db.dsn)
 	if err != nil {
 		return nil, err
 	}
 
+	connector := stdlib.GetConnector(*connConfig)
+	if breaker != nil {
+		connector = breaker.Connector(connector)
+	}
+
+	db := sql.OpenDB(connector)
+
 	db.SetMaxOpenConns(cfg.db.maxOpenConns)
 	db.SetMaxIdleConns(cfg.db.maxIdleConns)
 	db.SetConnMaxIdleTime(cfg.db.maxIdleTime)
@@ -96,3 +182,92 @@ func openDB(cfg config) (*sql.DB, error) {
 
 	return db, nil
 }
+
+// openReplicas opens a pool per replica DSN. Replicas that are down at start
+// only log; the health checker brings them in once they answer.
+func openReplicas(cfg config, primary *sql.DB) (*data.ReplicaSet, error) {
+	var replicas []data.Replica
+
+	for _, dsn := range cfg.db.replicas.dsns {
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			for _, r := range replicas {
+				r.DB.Close()
+			}
+			return nil, fmt.Errorf("replica %s: %w", redact(dsn), err)
+		}
+
+		db.SetMaxOpenConns(cfg.db.maxOpenConns)
+		db.SetMaxIdleConns(cfg.db.maxIdleConns)
+		db.SetConnMaxIdleTime(cfg.db.maxIdleTime)
+
+		replicas = append(replicas, data.Replica{Name: redact(dsn), DB: db})
+	}
+
+	return data.NewReplicaSet(primary, replicas...), nil
+}
+
+// loadPasswordPolicy builds the policy for new passwords, adding the
+// passwords in -password-denylist-file to the built-in ones.
+func loadPasswordPolicy(cfg config) (data.PasswordPolicy, error) {
+	policy := data.NewPasswordPolicy(cfg.password.minLength)
+
+	if cfg.password.denylistFile == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(cfg.password.denylistFile)
+	if err != nil {
+		return data.PasswordPolicy{}, err
+	}
+	defer f.Close()
+
+	err = policy.AddDenylist(f)
+	if err != nil {
+		return data.PasswordPolicy{}, fmt.Errorf("%s: %w", cfg.password.denylistFile, err)
+	}
+
+	return policy, nil
+}
+
+// loadAccessTokens returns the signer of access tokens with -token-mode=signed
+// and nil otherwise.
+func loadAccessTokens(cfg config) (*data.AccessTokens, error) {
+	if cfg.token.mode != "signed" {
+		return nil, nil
+	}
+
+	var keys []data.SigningKey
+
+	for _, spec := range cfg.token.keys {
+		key, err := data.ParseSigningKey(spec)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return data.NewAccessTokens(cfg.token.accessTTL, keys...)
+}
+
+func openCache(cfg config) (cache.Cache, error) {
+	if !cfg.cache.enabled {
+		return cache.NewMemory(), nil
+	}
+
+	switch cfg.cache.backend {
+	case "memory":
+		return cache.NewMemory(), nil
+	case "redis":
+		return cache.NewRESP(cache.RESPOptions{
+			Addr:     cfg.cache.redis.addr,
+			Password: cfg.cache.redis.password,
+			DB:       cfg.cache.redis.db,
+			Prefix:   bannerCachePrefix,
+			PoolSize: cfg.cache.redis.poolSize,
+			Timeout:  cfg.cache.redis.timeout,
+		})
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.cache.backend)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+	"golang.org/x/time/rate"
+)
+
+// newTestApplication wires an application the way main does, with the
+// default config overridden by args. db may be nil for tests that never
+// reach the database.
+func newTestApplication(t *testing.T, db *sql.DB, args ...string) *application {
+	t.Helper()
+
+	args = append([]string{"-db-dsn", "postgres://localhost/test"}, args...)
+
+	cfg, settings, src, err := parseConfig(args, flag.ContinueOnError, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := validator.New()
+	if validateConfig(v, cfg); !v.Valid() {
+		t.Fatalf("invalid config: %v", v.Errors)
+	}
+
+	passwords, err := loadPasswordPolicy(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accessTokens, err := loadAccessTokens(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bannerCache, err := openCache(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(cfg.logLevel)
+
+	app := &application{
+		config:       cfg,
+		logger:       slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: logLevel})),
+		logLevel:     logLevel,
+		limiter:      rate.NewLimiter(rate.Limit(cfg.limiter.rps), cfg.limiter.burst),
+		models:       data.NewModels(db, nil, cfg.db.timeouts),
+		tokens:       newTokenCache(cfg.breaker.tokenTTL),
+		accessTokens: accessTokens,
+		logins:       newLoginGuard(cfg),
+		passwords:    passwords,
+		cache:        bannerCache,
+		cacheLoads:   newCacheLoads(),
+		snapshot:     newBannerSnapshot(),
+		events:       newEventBroker(),
+	}
+
+	app.live.Store(&liveConfig{config: cfg, settings: settings, source: src, loadedAt: time.Now()})
+
+	return app
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
@@ -8,7 +10,6 @@ import (
 
 	"github.com/skraio/banner-service/internal/data"
 	"github.com/skraio/banner-service/internal/validator"
-	"golang.org/x/time/rate"
 )
 
 func (app *application) recoverPanic(next http.Handler) http.Handler {
@@ -24,12 +25,34 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
-func (app *application) rateLimit(next http.Handler) http.Handler {
-	limiter := rate.NewLimiter(rate.Limit(app.config.limiter.rps), app.config.limiter.burst)
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+
+		if !validator.Matches(requestID, validator.RequestIDRX) {
+			randomBytes := make([]byte, 16)
+
+			_, err := rand.Read(randomBytes)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			requestID = hex.EncodeToString(randomBytes)
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
 
+		r = app.contextSetRequestID(r, requestID)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) rateLimit(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.config.limiter.enabled {
-			if !limiter.Allow() {
+		if app.liveConfig().limiter.enabled {
+			if !app.limiter.Allow() {
 				app.rateLimitExceedResponse(w, r)
 				return
 			}
@@ -43,8 +66,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
 
+		// Logging in and refreshing carry their credentials in the body. A
+		// client may still send the access token that has just expired, and
+		// that must not get the request rejected.
 		authorizationHeader := r.Header.Get("Authorization")
-		if authorizationHeader == "" {
+		if authorizationHeader == "" || exchangesCredentials(r) {
 			r = app.contextSetUser(r, data.AnonymousUser)
 			next.ServeHTTP(w, r)
 			return
@@ -58,6 +84,21 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		token := headerParts[1]
 
+		// Signed access tokens replace opaque ones, which then only refresh
+		// them.
+		if app.accessTokens != nil {
+			user, err := app.accessTokens.Verify(token)
+			if err != nil {
+				app.invalidTokenResponse(w, r)
+				return
+			}
+
+			r = app.contextSetUser(r, user)
+
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		v := validator.New()
 
 		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
@@ -65,15 +106,22 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := app.models.Users.GetByToken(token)
+		user, err := app.models.Users.GetByToken(r.Context(), token)
 		if err != nil {
+			cached, ok := app.tokens.get(token)
+
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
 				app.invalidTokenResponse(w, r)
+				return
+			case databaseUnavailable(err) && ok:
+				user = cached
 			default:
 				app.serverErrorResponse(w, r, err)
+				return
 			}
-			return
+		} else if app.config.breaker.enabled {
+			app.tokens.set(token, user)
 		}
 
 		r = app.contextSetUser(r, user)
@@ -82,6 +130,12 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// exchangesCredentials reports whether r trades credentials in its body for
+// a token.
+func exchangesCredentials(r *http.Request) bool {
+	return r.Method == http.MethodPost && (r.URL.Path == "/token" || r.URL.Path == "/token/refresh")
+}
+
 func (app *application) requireRole(next http.HandlerFunc, allowedRoles ...data.Role) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
@@ -101,3 +155,14 @@ func (app *application) requireRole(next http.HandlerFunc, allowedRoles ...data.
 		app.forbiddenAccessResponse(w, r)
 	})
 }
+
+func (app *application) requireClientCert(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			app.clientCertRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"net/http"
+
+	bannerservice "github.com/skraio/banner-service"
+)
+
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+
+	_, err := w.Write(bannerservice.OpenAPI)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	bannerservice "github.com/skraio/banner-service"
+	"gopkg.in/yaml.v3"
+)
+
+// openAPISpec is the part of api.yaml that responses are checked against.
+type openAPISpec struct {
+	Paths      map[string]specPathItem `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*specSchema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type specPathItem struct {
+	Get    *specOperation `yaml:"get"`
+	Post   *specOperation `yaml:"post"`
+	Patch  *specOperation `yaml:"patch"`
+	Delete *specOperation `yaml:"delete"`
+}
+
+func (p specPathItem) operation(method string) *specOperation {
+	switch method {
+	case http.MethodGet:
+		return p.Get
+	case http.MethodPost:
+		return p.Post
+	case http.MethodPatch:
+		return p.Patch
+	case http.MethodDelete:
+		return p.Delete
+	}
+	return nil
+}
+
+type specOperation struct {
+	Responses map[string]specResponse `yaml:"responses"`
+}
+
+type specResponse struct {
+	Content map[string]struct {
+		Schema *specSchema `yaml:"schema"`
+	} `yaml:"content"`
+}
+
+type specSchema struct {
+	Ref                  string                 `yaml:"$ref"`
+	Type                 string                 `yaml:"type"`
+	Format               string                 `yaml:"format"`
+	Nullable             bool                   `yaml:"nullable"`
+	Enum                 []any                  `yaml:"enum"`
+	Required             []string               `yaml:"required"`
+	Properties           map[string]*specSchema `yaml:"properties"`
+	AdditionalProperties any                    `yaml:"additionalProperties"`
+	Items                *specSchema            `yaml:"items"`
+}
+
+var loadSpec = sync.OnceValues(func() (*openAPISpec, error) {
+	var spec openAPISpec
+
+	err := yaml.Unmarshal(bannerservice.OpenAPI, &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+})
+
+func testSpec(t *testing.T) *openAPISpec {
+	t.Helper()
+
+	spec, err := loadSpec()
+	if err != nil {
+		t.Fatalf("parsing api.yaml: %v", err)
+	}
+
+	return spec
+}
+
+// route returns the spec path that matches a request path, e.g. /banner/{id}
+// for /banner/7. A literal path wins, so /banner/trash isn't /banner/{id}.
+func (s *openAPISpec) route(path string) (string, bool) {
+	if _, ok := s.Paths[path]; ok {
+		return path, true
+	}
+
+	segments := strings.Split(path, "/")
+
+	for route := range s.Paths {
+		parts := strings.Split(route, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+
+		match := true
+		for i, part := range parts {
+			if part != segments[i] && !(strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}")) {
+				match = false
+				break
+			}
+		}
+
+		if match {
+			return route, true
+		}
+	}
+
+	return "", false
+}
+
+// checkResponse fails the test when the response to r isn't documented in
+// api.yaml: the status must be listed for the operation, and a JSON body must
+// match its schema. Error bodies are the {"error": ...} envelope or a Problem,
+// as the spec's description says, unless the status documents its own.
+func checkResponse(t *testing.T, r *http.Request, res *httptest.ResponseRecorder) {
+	t.Helper()
+
+	spec := testSpec(t)
+
+	route, ok := spec.route(r.URL.Path)
+	if !ok {
+		t.Errorf("%s %s: path isn't in api.yaml", r.Method, r.URL.Path)
+		return
+	}
+
+	op := spec.Paths[route].operation(r.Method)
+	if op == nil {
+		t.Errorf("%s %s: method isn't in api.yaml", r.Method, route)
+		return
+	}
+
+	documented, ok := op.Responses[strconv.Itoa(res.Code)]
+	if !ok {
+		t.Errorf("%s %s: status %d isn't documented, only %s", r.Method, route, res.Code, strings.Join(specKeys(op.Responses), ", "))
+		return
+	}
+
+	for _, err := range spec.responseErrors(documented, res) {
+		t.Errorf("%s %s: %d response: %s", r.Method, route, res.Code, err)
+	}
+}
+
+// checkErrorResponse checks the error body of a response that has no
+// operation in the spec, such as a 404 for an unknown path.
+func checkErrorResponse(t *testing.T, res *httptest.ResponseRecorder) {
+	t.Helper()
+
+	for _, err := range testSpec(t).errorBodyErrors(res) {
+		t.Errorf("%d response: %s", res.Code, err)
+	}
+}
+
+func (s *openAPISpec) responseErrors(documented specResponse, res *httptest.ResponseRecorder) []string {
+	mediaType, _, _ := mime.ParseMediaType(res.Header().Get("Content-Type"))
+
+	if res.Code == http.StatusNoContent || res.Code == http.StatusNotModified {
+		if res.Body.Len() > 0 {
+			return []string{"body must be empty"}
+		}
+		return nil
+	}
+
+	if content, ok := documented.Content[mediaType]; ok {
+		if !isJSON(mediaType) || content.Schema == nil {
+			return nil
+		}
+
+		var body any
+		if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+			return []string{fmt.Sprintf("invalid JSON: %v", err)}
+		}
+
+		return s.validate(content.Schema, body, "body")
+	}
+
+	if res.Code >= 400 && (len(documented.Content) == 0 || mediaType == problemContentType) {
+		return s.errorBodyErrors(res)
+	}
+
+	if len(documented.Content) == 0 {
+		return []string{fmt.Sprintf("undocumented %s body", mediaType)}
+	}
+
+	return []string{fmt.Sprintf("content type %q isn't one of %s", mediaType, strings.Join(specKeys(documented.Content), ", "))}
+}
+
+var errorEnvelopeSchema = &specSchema{
+	Type:       "object",
+	Required:   []string{"error"},
+	Properties: map[string]*specSchema{"error": {}},
+}
+
+func (s *openAPISpec) errorBodyErrors(res *httptest.ResponseRecorder) []string {
+	mediaType, _, _ := mime.ParseMediaType(res.Header().Get("Content-Type"))
+
+	var body any
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		return []string{fmt.Sprintf("invalid JSON error body: %v", err)}
+	}
+
+	switch mediaType {
+	case "application/json":
+		return s.validate(errorEnvelopeSchema, body, "body")
+	case problemContentType:
+		errs := s.validate(&specSchema{Ref: "#/components/schemas/Problem"}, body, "body")
+
+		if problem, ok := body.(map[string]any); ok && problem["status"] != float64(res.Code) {
+			errs = append(errs, fmt.Sprintf("body.status is %v, not %d", problem["status"], res.Code))
+		}
+
+		return errs
+	}
+
+	return []string{fmt.Sprintf("error body has content type %q", mediaType)}
+}
+
+// validate checks value, decoded from JSON, against schema. Objects are
+// closed: a property missing from properties is drift unless the schema
+// allows additionalProperties.
+func (s *openAPISpec) validate(schema *specSchema, value any, path string) []string {
+	if schema.Ref != "" {
+		name, ok := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+		if !ok || s.Components.Schemas[name] == nil {
+			return []string{fmt.Sprintf("%s: unresolved $ref %s", path, schema.Ref)}
+		}
+		return s.validate(s.Components.Schemas[name], value, path)
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: null isn't a nullable %s", path, schema.Type)}
+	}
+
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(value) }) {
+		return []string{fmt.Sprintf("%s: %v isn't one of %v", path, value, schema.Enum)}
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "object":
+		return s.validateObject(schema, value, path)
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T isn't an array", path, value)}
+		}
+
+		var errs []string
+		if schema.Items != nil {
+			for i, item := range items {
+				errs = append(errs, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+		return errs
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{fmt.Sprintf("%s: %T isn't a string", path, value)}
+		}
+
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return []string{fmt.Sprintf("%s: %q isn't a date-time", path, str)}
+			}
+		}
+		return nil
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return []string{fmt.Sprintf("%s: %v isn't an integer", path, value)}
+		}
+		return nil
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s: %T isn't a number", path, value)}
+		}
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %T isn't a boolean", path, value)}
+		}
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s: unknown schema type %q", path, schema.Type)}
+}
+
+func (s *openAPISpec) validateObject(schema *specSchema, value any, path string) []string {
+	object, ok := value.(map[string]any)
+	if !ok {
+		return []string{fmt.Sprintf("%s: %T isn't an object", path, value)}
+	}
+
+	var errs []string
+
+	for _, name := range schema.Required {
+		if _, ok := object[name]; !ok {
+			errs = append(errs, fmt.Sprintf("%s: missing required %s", path, name))
+		}
+	}
+
+	for _, name := range specKeys(object) {
+		field := path + "." + name
+
+		if property, ok := schema.Properties[name]; ok {
+			errs = append(errs, s.validate(property, object[name], field)...)
+			continue
+		}
+
+		switch additional := schema.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				errs = append(errs, fmt.Sprintf("%s: not allowed", field))
+			}
+		case map[string]any:
+			var extra specSchema
+			if raw, err := yaml.Marshal(additional); err == nil && yaml.Unmarshal(raw, &extra) == nil {
+				errs = append(errs, s.validate(&extra, object[name], field)...)
+			}
+		case nil:
+			if schema.Properties != nil {
+				errs = append(errs, fmt.Sprintf("%s: undocumented", field))
+			}
+		}
+	}
+
+	return errs
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || mediaType == problemContentType
+}
+
+func specKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/skraio/banner-service/internal/validator"
+	"golang.org/x/time/rate"
+)
+
+// reloadableSettings are applied live on SIGHUP. A reload that changes any
+// other setting is rejected as a whole.
+var reloadableSettings = map[string]bool{
+	"limiter-rps":        true,
+	"limiter-burst":      true,
+	"limiter-enabled":    true,
+	"cache-ttl":          true,
+	"cache-negative-ttl": true,
+	"log-level":          true,
+}
+
+// liveConfig is the config currently applied, including reloaded settings.
+// app.config keeps the config the server started with.
+type liveConfig struct {
+	config
+	settings map[string]string
+	source   configSource
+	loadedAt time.Time
+}
+
+func (app *application) liveConfig() *liveConfig {
+	return app.live.Load()
+}
+
+// startConfigReloader re-reads the config file and environment on SIGHUP.
+func (app *application) startConfigReloader(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	app.background(func() {
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				app.reloadConfig()
+			}
+		}
+	})
+}
+
+func (app *application) reloadConfig() {
+	current := app.liveConfig()
+
+	cfg, settings, src, err := parseConfig(os.Args[1:], flag.ContinueOnError, io.Discard)
+	if err != nil {
+		app.logger.Error("config reload rejected", "error", err.Error())
+		return
+	}
+
+	v := validator.New()
+
+	if validateConfig(v, cfg); !v.Valid() {
+		app.logger.Error("config reload rejected", "errors", v.Errors)
+		return
+	}
+
+	var changed, unsafe []string
+
+	for name, value := range settings {
+		if current.settings[name] == value {
+			continue
+		}
+
+		if reloadableSettings[name] {
+			changed = append(changed, name)
+		} else {
+			unsafe = append(unsafe, name)
+		}
+	}
+
+	sort.Strings(changed)
+	sort.Strings(unsafe)
+
+	if len(unsafe) > 0 {
+		app.logger.Error("config reload rejected, settings require a restart", "settings", unsafe)
+		return
+	}
+
+	if len(changed) == 0 {
+		app.logger.Info("config reloaded, nothing changed")
+		return
+	}
+
+	app.limiter.SetLimit(rate.Limit(cfg.limiter.rps))
+	app.limiter.SetBurst(cfg.limiter.burst)
+	app.logLevel.Set(cfg.logLevel)
+
+	app.live.Store(&liveConfig{config: cfg, settings: settings, source: src, loadedAt: time.Now()})
+
+	app.logger.Info("config reloaded", "settings", changed)
+}
+
+func (app *application) showConfigHandler(w http.ResponseWriter, r *http.Request) {
+	live := app.liveConfig()
+
+	env := envelope{
+		"config":    redactSettings(live.settings),
+		"file":      live.source.file,
+		"loaded_at": live.loadedAt,
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// startReplicaChecker pings the read replicas and takes those that don't
+// answer out of rotation until they do.
+func (app *application) startReplicaChecker(ctx context.Context) {
+	if app.replicas.Len() == 0 {
+		return
+	}
+
+	app.background(func() {
+		ticker := time.NewTicker(app.config.db.replicas.checkInterval)
+		defer ticker.Stop()
+
+		for {
+			for _, h := range app.replicas.CheckHealth(ctx, app.config.db.replicas.checkInterval) {
+				if h.Healthy {
+					app.logger.Info("read replica is up", "replica", h.Name)
+				} else {
+					app.logger.Warn("read replica is down, reading from the primary", "replica", h.Name, "error", h.Err.Error())
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"expvar"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
@@ -13,14 +14,68 @@ func (app *application) routes() http.Handler {
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
+	router.HandlerFunc(http.MethodGet, "/healthz", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/readyz", app.readinessHandler)
+	router.HandlerFunc(http.MethodGet, "/openapi.yaml", app.openAPIHandler)
+
 	router.HandlerFunc(http.MethodGet, "/user_banner", app.requireRole(app.showBannerHandler, data.RoleUser, data.RoleAdmin))
-	router.HandlerFunc(http.MethodGet, "/banner", app.requireRole(app.listFilteredBannersHandler, data.RoleAdmin))
-	router.HandlerFunc(http.MethodPost, "/banner", app.requireRole(app.createBannerHandler, data.RoleAdmin))
-	router.HandlerFunc(http.MethodPatch, "/banner/:id", app.requireRole(app.updateBannerHandler, data.RoleAdmin))
-	router.HandlerFunc(http.MethodDelete, "/banner/:id", app.requireRole(app.deleteBannerHandler, data.RoleAdmin))
+	router.HandlerFunc(http.MethodGet, "/banner", app.requireAdmin(app.listFilteredBannersHandler))
+	router.HandlerFunc(http.MethodPost, "/banner", app.requireAdmin(app.requireDatabase(app.createBannerHandler)))
+	router.HandlerFunc(http.MethodGet, "/banner/export", app.requireAdmin(app.exportBannersHandler))
+	router.HandlerFunc(http.MethodGet, "/banner/events", app.requireAdmin(app.bannerEventsHandler))
+	router.HandlerFunc(http.MethodGet, "/banner/trash", app.requireAdmin(app.listTrashHandler))
+	router.HandlerFunc(http.MethodPost, "/banner/:id", app.staticParam("import", app.requireAdmin(app.requireDatabase(app.importBannersHandler))))
+	router.HandlerFunc(http.MethodPost, "/banner/:id/restore", app.requireAdmin(app.requireDatabase(app.restoreBannerHandler)))
+	router.HandlerFunc(http.MethodPatch, "/banner/:id", app.requireAdmin(app.requireDatabase(app.updateBannerHandler)))
+	router.HandlerFunc(http.MethodDelete, "/banner/:id", app.requireAdmin(app.requireDatabase(app.deleteBannerHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/webhooks", app.requireAdmin(app.listWebhooksHandler))
+	router.HandlerFunc(http.MethodPost, "/webhooks", app.requireAdmin(app.requireDatabase(app.createWebhookHandler)))
+	router.HandlerFunc(http.MethodDelete, "/webhooks/:id", app.requireAdmin(app.requireDatabase(app.deleteWebhookHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/audit", app.requireAdmin(app.listAuditHandler))
+
+	router.HandlerFunc(http.MethodGet, "/admin/config", app.requireAdmin(app.showConfigHandler))
+	router.HandlerFunc(http.MethodGet, "/debug/vars", app.requireAdmin(expvar.Handler().ServeHTTP))
+
+	router.HandlerFunc(http.MethodPost, "/user", app.requireDatabase(app.createUserHandler))
+	router.HandlerFunc(http.MethodGet, "/users", app.requireAdmin(app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/users/:id", app.requireAdmin(app.showUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/users/:id", app.requireAdmin(app.requireDatabase(app.updateUserHandler)))
+	router.HandlerFunc(http.MethodDelete, "/users/:id", app.requireAdmin(app.requireDatabase(app.deleteUserHandler)))
+	router.HandlerFunc(http.MethodPatch, "/me", app.requireRole(app.requireDatabase(app.updateMeHandler), data.RoleUser, data.RoleAdmin))
+	router.HandlerFunc(http.MethodPost, "/token", app.requireDatabase(app.createTokenHandler))
+	router.HandlerFunc(http.MethodDelete, "/token", app.requireRole(app.revokeTokensHandler, data.RoleUser, data.RoleAdmin))
+
+	if app.accessTokens != nil {
+		router.HandlerFunc(http.MethodPost, "/token/refresh", app.requireDatabase(app.refreshTokenHandler))
+	}
+
+	return app.requestID(app.recoverPanic(app.rateLimit(app.authenticate(router))))
+}
+
+// requireAdmin allows admins only. With -tls-client-ca-file set they must also
+// present a verified client certificate.
+func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	handler := app.requireRole(next, data.RoleAdmin)
+
+	if app.config.tls.clientCAFile == "" {
+		return handler
+	}
+
+	return app.requireClientCert(handler)
+}
 
-	router.HandlerFunc(http.MethodPost, "/user", app.createUserHandler)
-	router.HandlerFunc(http.MethodPost, "/token", app.createTokenHandler)
+// staticParam serves POST /banner/import from the :id wildcard. httprouter
+// v1.3 can't register a static segment next to the wildcard that
+// /banner/:id/restore needs in the same method tree, so any other id is 404.
+func (app *application) staticParam(value string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if httprouter.ParamsFromContext(r.Context()).ByName("id") != value {
+			app.notFoundResponse(w, r)
+			return
+		}
 
-	return app.recoverPanic(app.rateLimit(app.authenticate(router)))
+		next.ServeHTTP(w, r)
+	}
 }
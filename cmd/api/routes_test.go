@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// registeredRoutes returns "METHOD /path" for every router.HandlerFunc call in
+// routes.go, with httprouter's :name parameters written as {name}.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+
+	file, err := parser.ParseFile(token.NewFileSet(), "routes.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	methods := map[string]string{
+		"MethodGet":    http.MethodGet,
+		"MethodPost":   http.MethodPost,
+		"MethodPatch":  http.MethodPatch,
+		"MethodDelete": http.MethodDelete,
+		"MethodPut":    http.MethodPut,
+	}
+
+	param := regexp.MustCompile(`:(\w+)`)
+
+	var routes []string
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) < 3 {
+			return true
+		}
+
+		fn, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || fn.Sel.Name != "HandlerFunc" {
+			return true
+		}
+
+		method, ok := call.Args[0].(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		path, ok := call.Args[1].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+
+		unquoted, err := strconv.Unquote(path.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A handler wrapped in staticParam serves a literal segment from the
+		// last wildcard.
+		if static, ok := staticParamValue(call.Args[2]); ok {
+			unquoted = unquoted[:strings.LastIndex(unquoted, ":")] + static
+		}
+
+		routes = append(routes, methods[method.Sel.Name]+" "+param.ReplaceAllString(unquoted, "{$1}"))
+		return true
+	})
+
+	sort.Strings(routes)
+	return routes
+}
+
+// staticParamValue returns the value of an app.staticParam("value", ...)
+// handler.
+func staticParamValue(handler ast.Expr) (string, bool) {
+	call, ok := handler.(*ast.CallExpr)
+	if !ok || len(call.Args) == 0 {
+		return "", false
+	}
+
+	fn, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || fn.Sel.Name != "staticParam" {
+		return "", false
+	}
+
+	lit, ok := call.Args[0].(*ast.BasicLit)
+	if !ok {
+		return "", false
+	}
+
+	value, err := strconv.Unquote(lit.Value)
+	return value, err == nil
+}
+
+func specOperations(t *testing.T) []string {
+	t.Helper()
+
+	var ops []string
+
+	for path, item := range testSpec(t).Paths {
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete} {
+			if item.operation(method) != nil {
+				ops = append(ops, method+" "+path)
+			}
+		}
+	}
+
+	sort.Strings(ops)
+	return ops
+}
+
+func TestRoutesMatchSpec(t *testing.T) {
+	routes := registeredRoutes(t)
+	ops := specOperations(t)
+
+	if len(routes) == 0 {
+		t.Fatal("no routes found in routes.go")
+	}
+
+	documented := make(map[string]bool, len(ops))
+	for _, op := range ops {
+		documented[op] = true
+	}
+
+	registered := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		registered[route] = true
+
+		if !documented[route] {
+			t.Errorf("%s is served but missing from api.yaml", route)
+		}
+	}
+
+	for _, op := range ops {
+		if !registered[op] {
+			t.Errorf("%s is in api.yaml but not registered in routes()", op)
+		}
+	}
+}
+
+// TestSpecOperationsAreServed sends an anonymous request to every operation
+// in api.yaml. None may fall through to the router's 404 or 405.
+func TestSpecOperationsAreServed(t *testing.T) {
+	app := newTestApplication(t, nil, signedTokenArgs()...)
+	handler := app.routes()
+
+	param := regexp.MustCompile(`\{\w+\}`)
+
+	for _, op := range specOperations(t) {
+		method, path, _ := strings.Cut(op, " ")
+
+		// The stream would never end; the route is covered by the contract
+		// tests.
+		if path == "/banner/events" {
+			continue
+		}
+
+		r := httptest.NewRequest(method, param.ReplaceAllString(path, "1"), strings.NewReader(""))
+		r.Header.Set("Accept", problemContentType)
+
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, r)
+
+		var problem struct {
+			Code string `json:"code"`
+		}
+		_ = json.Unmarshal(res.Body.Bytes(), &problem)
+
+		if problem.Code == codeNotFound || problem.Code == codeMethodNotAllowed {
+			t.Errorf("%s: the router doesn't serve it: %d %s", op, res.Code, res.Body)
+		}
+
+		checkResponse(t, r, res)
+	}
+}
+
+// TestSpecSchemasResolve checks that every $ref in a response schema points at
+// a component and every schema type is one the harness knows.
+func TestSpecSchemasResolve(t *testing.T) {
+	spec := testSpec(t)
+
+	var walk func(schema *specSchema, at string)
+	walk = func(schema *specSchema, at string) {
+		if schema == nil {
+			return
+		}
+
+		if schema.Ref != "" {
+			name, _ := strings.CutPrefix(schema.Ref, "#/components/schemas/")
+			if spec.Components.Schemas[name] == nil {
+				t.Errorf("%s: unresolved $ref %s", at, schema.Ref)
+			}
+			return
+		}
+
+		switch schema.Type {
+		case "", "object", "array", "string", "integer", "number", "boolean":
+		default:
+			t.Errorf("%s: unknown type %q", at, schema.Type)
+		}
+
+		for name, property := range schema.Properties {
+			walk(property, at+"."+name)
+		}
+		walk(schema.Items, at+"[]")
+	}
+
+	for path, item := range spec.Paths {
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete} {
+			op := item.operation(method)
+			if op == nil {
+				continue
+			}
+
+			for status, response := range op.Responses {
+				for mediaType, content := range response.Content {
+					walk(content.Schema, method+" "+path+" "+status+" "+mediaType)
+				}
+			}
+		}
+	}
+
+	for name, schema := range spec.Components.Schemas {
+		walk(schema, "components.schemas."+name)
+	}
+}
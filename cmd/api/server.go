@@ -9,16 +9,53 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 )
 
 func (app *application) serve() error {
 	srv := &http.Server{
-		Addr:     fmt.Sprintf(":%d", app.config.port),
-		Handler:  app.routes(),
-		ErrorLog: slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+		Addr:              fmt.Sprintf(":%d", app.config.port),
+		Handler:           app.routes(),
+		ReadHeaderTimeout: app.config.server.readHeaderTimeout,
+		ReadTimeout:       app.config.server.readTimeout,
+		WriteTimeout:      app.config.server.writeTimeout,
+		IdleTimeout:       app.config.server.idleTimeout,
+		ErrorLog:          slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
 	}
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tlsEnabled := app.config.tls.certFile != ""
+
+	if tlsEnabled {
+		cr, err := newCertReloader(app.config.tls.certFile, app.config.tls.keyFile)
+		if err != nil {
+			return err
+		}
+
+		srv.TLSConfig, err = app.tlsConfig(cr)
+		if err != nil {
+			return err
+		}
+
+		app.startCertReloader(ctx, cr)
+	}
+
+	srv.RegisterOnShutdown(app.events.close)
+
+	app.publishBreakerMetrics()
+	app.publishEventMetrics()
+
+	app.startReplicaChecker(ctx)
+	app.startTrashPurger(ctx)
+	app.startLoginSweeper(ctx)
+	app.startEventListener(ctx)
+	app.startRevocationListener(ctx)
+	app.startCacheInvalidator(ctx)
+	app.startSnapshotRefresher(ctx)
+	app.startWebhookWorkers(ctx)
+	app.startConfigReloader(ctx)
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -28,23 +65,38 @@ func (app *application) serve() error {
 
 		app.logger.Info("shutting down a server", "signal", s.String())
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), app.config.shutdownTimeout)
+		defer shutdownCancel()
 
-		err := srv.Shutdown(ctx)
+		err := srv.Shutdown(shutdownCtx)
 		if err != nil {
 			shutdownError <- err
 		}
 
 		app.logger.Info("completing background tasks", "addr", srv.Addr)
 
+		cancel()
+
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
 
-	app.logger.Info("starting server", "addr", srv.Addr)
+	if app.keepSnapshot() {
+		err := app.loadSnapshot(ctx)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "snapshot refresher")
+		}
+	}
+
+	app.logger.Info("starting server", "addr", srv.Addr, "tls", tlsEnabled)
 
-	err := srv.ListenAndServe()
+	var err error
+
+	if tlsEnabled {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+)
+
+type snapshotKey struct {
+	featureID int64
+	tagID     int64
+}
+
+// bannerSnapshot indexes every active banner by feature and tag. Pairs evicted
+// while a reload is running are evicted again from the reloaded index, since
+// the reload may have read them before the change. Loads run one at a time,
+// so that a load starting doesn't drop the evictions another one still needs.
+type bannerSnapshot struct {
+	load sync.Mutex
+
+	mu       sync.RWMutex
+	index    map[snapshotKey]*data.Banner
+	loadedAt time.Time
+	loading  bool
+	pending  []snapshotKey
+	reload   chan struct{}
+}
+
+func newBannerSnapshot() *bannerSnapshot {
+	return &bannerSnapshot{reload: make(chan struct{}, 1)}
+}
+
+func (s *bannerSnapshot) get(featureID, tagID int64) (*data.Banner, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	banner, ok := s.index[snapshotKey{featureID, tagID}]
+	return banner, ok
+}
+
+func (s *bannerSnapshot) ready() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return !s.loadedAt.IsZero()
+}
+
+func (s *bannerSnapshot) beginLoad() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.loading = true
+	s.pending = nil
+}
+
+func (s *bannerSnapshot) endLoad(banners []*data.Banner) {
+	index := make(map[snapshotKey]*data.Banner)
+
+	for _, banner := range banners {
+		for _, tagID := range banner.TagIDs {
+			index[snapshotKey{banner.FeatureID, tagID}] = banner
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if banners != nil {
+		for _, key := range s.pending {
+			delete(index, key)
+		}
+
+		s.index = index
+		s.loadedAt = time.Now()
+	}
+
+	s.loading = false
+	s.pending = nil
+}
+
+func (s *bannerSnapshot) evict(keys ...snapshotKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.index, key)
+	}
+
+	if s.loading {
+		s.pending = append(s.pending, keys...)
+	}
+}
+
+// requestReload asks the refresher to reload the snapshot as soon as it can.
+func (s *bannerSnapshot) requestReload() {
+	select {
+	case s.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (app *application) loadSnapshot(ctx context.Context) error {
+	app.snapshot.load.Lock()
+	defer app.snapshot.load.Unlock()
+
+	app.snapshot.beginLoad()
+
+	banners, err := app.models.Banners.GetActive(ctx)
+	if err != nil {
+		app.snapshot.endLoad(nil)
+		return err
+	}
+
+	app.snapshot.endLoad(banners)
+
+	app.logger.Info("banner snapshot loaded", "banners", len(banners))
+
+	return nil
+}
+
+func (app *application) startSnapshotRefresher(ctx context.Context) {
+	if !app.keepSnapshot() {
+		return
+	}
+
+	app.background(func() {
+		ticker := time.NewTicker(app.config.snapshot.refreshInterval)
+		defer ticker.Stop()
+
+		for {
+			var retry <-chan time.Time
+			if !app.snapshot.ready() {
+				retry = time.After(5 * time.Second)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-app.snapshot.reload:
+			case <-retry:
+			}
+
+			err := app.loadSnapshot(ctx)
+			if err != nil {
+				app.logger.Error(err.Error(), "task", "snapshot refresher")
+			}
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the TLS certificate from disk and swaps it when the
+// certificate or key file changes.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	cr := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	_, err := cr.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return cr, nil
+}
+
+func (cr *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	return cr.cert, nil
+}
+
+// reload loads the key pair when either file is newer than the one served.
+// A pair that fails to load keeps the current certificate in place.
+func (cr *certReloader) reload() (bool, error) {
+	modTime, err := latestModTime(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	cr.mu.RLock()
+	current := cr.modTime
+	cr.mu.RUnlock()
+
+	if !modTime.After(current) {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.mu.Unlock()
+
+	return true, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// tlsConfig builds the server TLS config. With a client CA, certificates are
+// verified when offered and admin routes reject requests without one.
+func (app *application) tlsConfig(cr *certReloader) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.getCertificate,
+	}
+
+	if app.config.tls.clientCAFile != "" {
+		pem, err := os.ReadFile(app.config.tls.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", app.config.tls.clientCAFile)
+		}
+
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return cfg, nil
+}
+
+func (app *application) startCertReloader(ctx context.Context, cr *certReloader) {
+	app.background(func() {
+		ticker := time.NewTicker(app.config.tls.reloadInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reloaded, err := cr.reload()
+				switch {
+				case err != nil && !errors.Is(err, os.ErrNotExist):
+					app.logger.Error(err.Error(), "task", "tls reloader")
+				case err != nil:
+					app.logger.Warn("tls certificate file missing, keeping the current one", "task", "tls reloader")
+				case reloaded:
+					app.logger.Info("tls certificate reloaded", "cert", cr.certFile)
+				}
+			}
+		}
+	})
+}
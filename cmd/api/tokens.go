@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/skraio/banner-service/internal/data"
 	"github.com/skraio/banner-service/internal/validator"
@@ -30,10 +35,18 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	user, err := app.models.Users.GetByUserName(input.Username)
+	attempt, retryAfter := app.logins.begin(input.Username, clientIP(r))
+	if retryAfter > 0 {
+		app.loginLockedResponse(w, r, retryAfter)
+		return
+	}
+	defer app.logins.release(attempt)
+
+	user, err := app.models.Users.GetByUserName(r.Context(), input.Username)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			app.loginFailed(r, attempt)
 			app.invalidCredentialsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -48,18 +61,190 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	if !match {
+		app.loginFailed(r, attempt)
 		app.invalidCredentialsResponse(w, r)
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.UserID)
+	app.logins.succeed(attempt)
+
+	token, access, err := app.newTokens(r.Context(), user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	env := envelope{"new_token": token}
+	if access != nil {
+		env = envelope{"new_token": access, "refresh_token": token}
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// refreshTokenHandler issues a new access token for a refresh token. The user
+// is read again, so a role change applies from the next refresh.
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetByToken(r.Context(), input.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	access, err := app.accessTokens.New(user)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"new_token": access}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) revokeTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.models.Tokens.DeleteAll(r.Context(), user.UserID)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"new_token": token}, nil)
+	app.forgetUserTokens(user.UserID)
+
+	err = app.writeJSON(w, http.StatusNoContent, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// forgetUserTokens stops this instance from accepting the user's tokens
+// once the database has revoked them: opaque tokens cached for a database
+// outage and access tokens issued before now.
+func (app *application) forgetUserTokens(userID int64) {
+	app.tokens.forgetUser(userID)
+
+	if app.accessTokens != nil {
+		app.accessTokens.Revoke(userID)
+	}
+}
+
+// newTokens stores a new opaque token for the user. With signed tokens it
+// also signs an access token, and the opaque token only refreshes it.
+func (app *application) newTokens(ctx context.Context, user *data.User) (*data.Token, *data.AccessToken, error) {
+	token, err := app.models.Tokens.New(ctx, user.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if app.accessTokens == nil {
+		return token, nil, nil
+	}
+
+	access, err := app.accessTokens.New(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, access, nil
+}
+
+// revocationsReload is how often revocations are read again in full, in case
+// a notification was lost while the listener reconnected.
+const revocationsReload = time.Minute
+
+// startRevocationListener applies token revocations made on any instance to
+// the signed access tokens this one accepts. Revocations still within the
+// token lifetime are loaded before the server starts, again whenever the
+// listener connection is restored and every revocationsReload.
+func (app *application) startRevocationListener(ctx context.Context) {
+	if app.accessTokens == nil {
+		return
+	}
+
+	app.loadRevocations(ctx)
+
+	notify := app.listen(ctx, "revocation listener", data.TokenRevocationsChannel)
+
+	app.background(func() {
+		reload := time.NewTicker(revocationsReload)
+		defer reload.Stop()
+
+		purge := time.NewTicker(time.Hour)
+		defer purge.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-notify:
+				// A nil notification marks a lost or restored connection.
+				if n == nil {
+					app.loadRevocations(ctx)
+					continue
+				}
+				app.applyRevocation(n.Payload)
+			case <-reload.C:
+				app.loadRevocations(ctx)
+			case <-purge.C:
+				_, err := app.models.Tokens.PurgeRevocations(ctx, time.Now().Add(-app.config.token.accessTTL))
+				if err != nil {
+					app.logger.Error(err.Error(), "task", "revocation listener")
+				}
+			}
+		}
+	})
+}
+
+func (app *application) loadRevocations(ctx context.Context) {
+	revocations, err := app.models.Tokens.Revocations(ctx, time.Now().Add(-app.config.token.accessTTL))
+	if err != nil {
+		app.logger.Error(err.Error(), "task", "revocation listener")
+		return
+	}
+
+	for userID, before := range revocations {
+		app.accessTokens.RevokeBefore(userID, before)
+	}
+}
+
+// applyRevocation applies a "user_id unix_time" notification.
+func (app *application) applyRevocation(payload string) {
+	id, unix, _ := strings.Cut(payload, " ")
+
+	userID, idErr := strconv.ParseInt(id, 10, 64)
+	before, unixErr := strconv.ParseInt(unix, 10, 64)
+	if idErr != nil || unixErr != nil {
+		app.logger.Error(fmt.Sprintf("invalid revocation %q", payload), "task", "revocation listener")
+		return
+	}
+
+	app.accessTokens.RevokeBefore(userID, time.Unix(before, 0))
+}
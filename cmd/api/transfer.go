@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+var csvHeader = []string{"banner_id", "feature_id", "tag_ids", "title", "text", "url", "is_active", "created_at", "updated_at"}
+
+type importLineError struct {
+	Line   int               `json:"line"`
+	Errors map[string]string `json:"errors"`
+}
+
+func (app *application) exportBannersHandler(w http.ResponseWriter, r *http.Request) {
+	var filters data.AdminFilters
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	filters.FeatureID = app.readInt(qs, "feature_id", 0, data.ReadIntOptions{Required: false, IsID: true}, v)
+	filters.TagID = app.readInt(qs, "tag_id", 0, data.ReadIntOptions{Required: false, IsID: true}, v)
+	filters.Limit = app.readInt(qs, "limit", 0, data.ReadIntOptions{Required: false, IsID: false}, v)
+	filters.Offset = app.readInt(qs, "offset", 0, data.ReadIntOptions{Required: false, IsID: false}, v)
+
+	format := qs.Get("format")
+	if format == "" {
+		format = formatNDJSON
+	}
+	v.Check(format == formatNDJSON || format == formatCSV, "format", "must be either ndjson or csv")
+
+	if data.ValidateExportFilters(v, filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Large exports outlive the server's write timeout.
+	if err := clearDeadlines(http.NewResponseController(w)); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	enc := json.NewEncoder(bw)
+
+	started := false
+	start := func() error {
+		started = true
+
+		if format == formatCSV {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", `attachment; filename="banners.csv"`)
+			w.WriteHeader(http.StatusOK)
+			return cw.Write(csvHeader)
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="banners.ndjson"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	err := app.models.Banners.Export(r.Context(), filters, func(banner *data.Banner) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+
+		if format == formatCSV {
+			return cw.Write(bannerToCSV(banner))
+		}
+		return enc.Encode(banner)
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err != nil {
+		if !started {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		app.logError(r, err)
+		return
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		app.logError(r, err)
+		return
+	}
+
+	if err := bw.Flush(); err != nil {
+		app.logError(r, err)
+	}
+}
+
+func (app *application) importBannersHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	dryRun := app.readBool(r.URL.Query(), "dry_run", false, v)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	maxBytes := 10_485_760
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	var (
+		banners []*data.Banner
+		report  []importLineError
+		err     error
+	)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		banners, report, err = readBannersCSV(r.Body)
+	} else {
+		banners, report, err = readBannersNDJSON(r.Body)
+	}
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+
+	if len(banners) == 0 && len(report) == 0 {
+		app.badRequestResponse(w, r, errors.New("body must not be empty"))
+		return
+	}
+
+	if len(report) > 0 {
+		app.failedImportResponse(w, r, report)
+		return
+	}
+
+	if dryRun {
+		err = app.writeJSON(w, http.StatusOK, envelope{"dry_run": true, "valid": len(banners)}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Banners.InsertMany(r.Context(), banners, app.contextGetActor(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	ids := make([]int64, 0, len(banners))
+	for _, banner := range banners {
+		ids = append(ids, banner.BannerID)
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"imported": len(ids), "banner_ids": ids}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func readBannersNDJSON(body io.Reader) ([]*data.Banner, []importLineError, error) {
+	var (
+		banners []*data.Banner
+		report  []importLineError
+	)
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var input data.Banner
+
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(&input); err != nil {
+			report = append(report, importLineError{Line: line, Errors: map[string]string{"line": err.Error()}})
+			continue
+		}
+
+		banner, lineErrors := newImportedBanner(input)
+		if lineErrors != nil {
+			report = append(report, importLineError{Line: line, Errors: lineErrors})
+			continue
+		}
+
+		banners = append(banners, banner)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return banners, report, nil
+}
+
+func readBannersCSV(body io.Reader) ([]*data.Banner, []importLineError, error) {
+	var (
+		banners []*data.Banner
+		report  []importLineError
+	)
+
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for _, name := range []string{"feature_id", "tag_ids", "title", "text", "url", "is_active"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("csv header is missing column %q", name)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		line, _ := cr.FieldPos(0)
+
+		if err != nil {
+			var parseError *csv.ParseError
+			if errors.As(err, &parseError) {
+				report = append(report, importLineError{Line: parseError.Line, Errors: map[string]string{"line": parseError.Err.Error()}})
+				continue
+			}
+			return nil, nil, err
+		}
+
+		input, lineErrors := bannerFromCSV(record, columns)
+		if lineErrors == nil {
+			var banner *data.Banner
+			banner, lineErrors = newImportedBanner(*input)
+			if lineErrors == nil {
+				banners = append(banners, banner)
+				continue
+			}
+		}
+
+		report = append(report, importLineError{Line: line, Errors: lineErrors})
+	}
+
+	return banners, report, nil
+}
+
+func newImportedBanner(input data.Banner) (*data.Banner, map[string]string) {
+	banner := &data.Banner{
+		TagIDs:    input.TagIDs,
+		FeatureID: input.FeatureID,
+		Content:   input.Content,
+		IsActive:  input.IsActive,
+	}
+
+	v := validator.New()
+
+	if data.ValidateBanner(v, banner); !v.Valid() {
+		return nil, v.Errors
+	}
+
+	return banner, nil
+}
+
+func bannerToCSV(banner *data.Banner) []string {
+	tagIDs := make([]string, 0, len(banner.TagIDs))
+	for _, id := range banner.TagIDs {
+		tagIDs = append(tagIDs, strconv.FormatInt(id, 10))
+	}
+
+	return []string{
+		strconv.FormatInt(banner.BannerID, 10),
+		strconv.FormatInt(banner.FeatureID, 10),
+		strings.Join(tagIDs, ";"),
+		banner.Content.Title,
+		banner.Content.Text,
+		banner.Content.URL,
+		strconv.FormatBool(banner.IsActive),
+		banner.CreatedAt.Format(time.RFC3339Nano),
+		banner.UpdatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+func bannerFromCSV(record []string, columns map[string]int) (*data.Banner, map[string]string) {
+	v := validator.New()
+
+	field := func(name string) string {
+		i := columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var banner data.Banner
+
+	featureID, err := strconv.ParseInt(field("feature_id"), 10, 64)
+	v.Check(err == nil, "feature_id", "must be an integer value")
+	banner.FeatureID = featureID
+
+	if s := field("tag_ids"); s != "" {
+		for _, part := range strings.Split(s, ";") {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil {
+				v.AddError("tag_ids", "must be a list of integers separated by ';'")
+				break
+			}
+			banner.TagIDs = append(banner.TagIDs, id)
+		}
+	}
+
+	banner.Content.Title = field("title")
+	banner.Content.Text = field("text")
+	banner.Content.URL = field("url")
+
+	isActive, err := strconv.ParseBool(field("is_active"))
+	v.Check(err == nil, "is_active", "must be a bool value")
+	banner.IsActive = isActive
+
+	if !v.Valid() {
+		return nil, v.Errors
+	}
+
+	return &banner, nil
+}
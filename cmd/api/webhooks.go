@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+const maxWebhookBackoff = 6 * time.Hour
+
+// maxWebhookPollBackoff caps the wait between outbox polls that fail.
+const maxWebhookPollBackoff = time.Minute
+
+type webhookPayload struct {
+	DeliveryID        int64           `json:"delivery_id"`
+	EventID           int64           `json:"event_id"`
+	Type              string          `json:"type"`
+	BannerID          int64           `json:"banner_id"`
+	Banner            json.RawMessage `json:"banner"`
+	PreviousFeatureID *int64          `json:"previous_feature_id,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+}
+
+func (app *application) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		URL        string  `json:"url"`
+		Secret     string  `json:"secret"`
+		FeatureIDs []int64 `json:"feature_ids"`
+		IsActive   *bool   `json:"is_active"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	webhook := &data.Webhook{
+		URL:        input.URL,
+		Secret:     input.Secret,
+		FeatureIDs: input.FeatureIDs,
+		IsActive:   true,
+	}
+
+	if input.IsActive != nil {
+		webhook.IsActive = *input.IsActive
+	}
+
+	if webhook.Secret == "" {
+		webhook.Secret, err = data.GenerateWebhookSecret()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	v := validator.New()
+
+	if data.ValidateWebhook(v, webhook); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Webhooks.Insert(r.Context(), webhook)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"webhook": webhook}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := app.models.Webhooks.GetAll(r.Context())
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"webhooks": webhooks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.webhookNotFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.Webhooks.Delete(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.webhookNotFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusNoContent, nil, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) startWebhookWorkers(ctx context.Context) {
+	client := &http.Client{Timeout: app.config.webhooks.timeout}
+
+	for i := 0; i < app.config.webhooks.workers; i++ {
+		app.background(func() {
+			poll := app.config.webhooks.pollInterval
+			maxWait := max(poll, maxWebhookPollBackoff)
+			wait := poll
+
+			for {
+				deliveries, err := app.models.Webhooks.ClaimDeliveries(ctx, 10, 2*app.config.webhooks.timeout)
+				if err != nil {
+					// Back off while the database is down, so that the
+					// error is logged about once a minute at most.
+					if wait == poll || wait == maxWait {
+						app.logger.Error(err.Error(), "task", "webhook worker")
+					}
+					wait = min(2*wait, maxWait)
+				} else {
+					wait = poll
+				}
+
+				for _, delivery := range deliveries {
+					if ctx.Err() != nil {
+						return
+					}
+					app.deliverWebhook(ctx, client, delivery)
+				}
+
+				if len(deliveries) > 0 {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		})
+	}
+
+	app.background(func() {
+		purge := time.NewTicker(time.Hour)
+		defer purge.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-purge.C:
+				_, err := app.models.Webhooks.PurgeDeliveries(ctx, app.config.webhooks.retention)
+				if err != nil {
+					app.logger.Error(err.Error(), "task", "webhook purger")
+				}
+			}
+		}
+	})
+}
+
+func (app *application) deliverWebhook(ctx context.Context, client *http.Client, delivery *data.WebhookDelivery) {
+	err := sendWebhook(ctx, client, delivery)
+	if ctx.Err() != nil {
+		// The claim lease runs out and another worker retries the delivery.
+		return
+	}
+
+	if err == nil {
+		err = app.models.Webhooks.MarkDelivered(ctx, delivery.DeliveryID)
+		if err != nil {
+			app.logger.Error(err.Error(), "task", "webhook worker", "delivery_id", delivery.DeliveryID)
+		}
+		return
+	}
+
+	giveUp := delivery.Attempts >= app.config.webhooks.maxAttempts
+	backoff := webhookBackoff(app.config.webhooks.backoff, delivery.Attempts)
+
+	app.logger.Warn("webhook delivery failed", "delivery_id", delivery.DeliveryID, "attempts", delivery.Attempts, "give_up", giveUp, "error", err.Error())
+
+	err = app.models.Webhooks.MarkFailed(ctx, delivery.DeliveryID, err, backoff, giveUp)
+	if err != nil {
+		app.logger.Error(err.Error(), "task", "webhook worker", "delivery_id", delivery.DeliveryID)
+	}
+}
+
+// webhookBackoff returns the wait after the given failed attempt: base,
+// doubled on every further attempt and capped at maxWebhookBackoff.
+func webhookBackoff(base time.Duration, attempts int) time.Duration {
+	backoff := base << min(max(attempts-1, 0), 20)
+	if backoff <= 0 || backoff > maxWebhookBackoff {
+		return maxWebhookBackoff
+	}
+	return backoff
+}
+
+func sendWebhook(ctx context.Context, client *http.Client, delivery *data.WebhookDelivery) error {
+	body, err := json.Marshal(webhookPayload{
+		DeliveryID:        delivery.DeliveryID,
+		EventID:           delivery.EventID,
+		Type:              delivery.EventType,
+		BannerID:          delivery.BannerID,
+		Banner:            delivery.Payload,
+		PreviousFeatureID: delivery.PreviousFeatureID,
+		CreatedAt:         delivery.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", strconv.FormatInt(delivery.DeliveryID, 10))
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", signWebhook(delivery.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// signWebhook returns the HMAC-SHA256 of "<timestamp>.<body>", so receivers
+// can reject replays of old deliveries.
+func signWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/testdb"
+)
+
+func TestSendWebhookSignsTheBody(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+
+	got := make(chan received, 1)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := &data.WebhookDelivery{
+		DeliveryID: 7,
+		EventID:    42,
+		EventType:  data.EventUpdated,
+		BannerID:   3,
+		Payload:    json.RawMessage(`{"banner_id":3}`),
+		Attempts:   1,
+		CreatedAt:  time.Date(2024, 4, 13, 14, 27, 24, 0, time.UTC),
+		URL:        receiver.URL,
+		Secret:     secret,
+	}
+
+	err := sendWebhook(context.Background(), receiver.Client(), delivery)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-got
+
+	if ct := r.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if id := r.header.Get("X-Webhook-ID"); id != "7" {
+		t.Errorf("X-Webhook-ID = %q, want 7", id)
+	}
+	if event := r.header.Get("X-Webhook-Event"); event != data.EventUpdated {
+		t.Errorf("X-Webhook-Event = %q, want %s", event, data.EventUpdated)
+	}
+
+	timestamp, err := strconv.ParseInt(r.header.Get("X-Webhook-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("X-Webhook-Timestamp: %v", err)
+	}
+	if d := time.Since(time.Unix(timestamp, 0)); d < -time.Second || d > time.Minute {
+		t.Errorf("X-Webhook-Timestamp is %v off", d)
+	}
+
+	// Verify the way a receiver would, independently of signWebhook.
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(r.body)
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if sig := r.header.Get("X-Webhook-Signature"); !hmac.Equal([]byte(sig), []byte(want)) {
+		t.Errorf("X-Webhook-Signature = %q, want %q", sig, want)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(r.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.DeliveryID != 7 || payload.EventID != 42 || payload.Type != data.EventUpdated || payload.BannerID != 3 {
+		t.Errorf("payload = %+v", payload)
+	}
+	if string(payload.Banner) != `{"banner_id":3}` {
+		t.Errorf("payload banner = %s", payload.Banner)
+	}
+}
+
+func TestSendWebhookFailsOnNon2xx(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusNoContent, false},
+		{http.StatusMovedPermanently, true},
+		{http.StatusBadRequest, true},
+		{http.StatusInternalServerError, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.status), func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.status == http.StatusMovedPermanently {
+					// A redirect that isn't followed.
+					w.Header().Set("Location", "/")
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			client := receiver.Client()
+			client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+			err := sendWebhook(context.Background(), client, &data.WebhookDelivery{URL: receiver.URL, Secret: "secret"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, maxWebhookBackoff},
+		{1000, maxWebhookBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := webhookBackoff(time.Minute, tt.attempts); got != tt.want {
+			t.Errorf("webhookBackoff(1m, %d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// webhookDeliveryState is the state of the only delivery in the outbox.
+type webhookDeliveryState struct {
+	attempts  int
+	lastError string
+	delivered bool
+	failed    bool
+	due       bool
+}
+
+func getWebhookDelivery(t *testing.T, app *application) webhookDeliveryState {
+	t.Helper()
+
+	var s webhookDeliveryState
+
+	err := app.models.Webhooks.DB.QueryRow(`
+        SELECT attempts, last_error, delivered_at IS NOT NULL, failed_at IS NOT NULL, next_attempt_at <= NOW()
+        FROM webhook_deliveries`).Scan(&s.attempts, &s.lastError, &s.delivered, &s.failed, &s.due)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+// enqueueWebhookDelivery registers a webhook for url and creates a banner
+// whose event is queued for it.
+func enqueueWebhookDelivery(t *testing.T, app *application, url string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	err := app.models.Webhooks.Insert(ctx, &data.Webhook{
+		URL:        url,
+		Secret:     "0123456789abcdef",
+		FeatureIDs: []int64{1},
+		IsActive:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = app.models.Banners.Insert(ctx, &data.Banner{
+		FeatureID: 1,
+		TagIDs:    []int64{1},
+		Content:   data.Content{Title: "title", Text: "text", URL: "https://example.com"},
+		IsActive:  true,
+	}, data.Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func claimWebhookDelivery(t *testing.T, app *application) *data.WebhookDelivery {
+	t.Helper()
+
+	deliveries, err := app.models.Webhooks.ClaimDeliveries(context.Background(), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("claimed %d deliveries, want 1", len(deliveries))
+	}
+
+	return deliveries[0]
+}
+
+func TestDeliverWebhookRetriesWithBackoffAndGivesUp(t *testing.T) {
+	db := testdb.Open(t)
+	app := newTestApplication(t, db, "-webhooks-max-attempts", "3", "-webhooks-backoff", "1h")
+
+	var hits atomic.Int64
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	enqueueWebhookDelivery(t, app, receiver.URL)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		delivery := claimWebhookDelivery(t, app)
+		if delivery.Attempts != attempt {
+			t.Fatalf("attempt %d: claimed with attempts = %d", attempt, delivery.Attempts)
+		}
+
+		app.deliverWebhook(context.Background(), receiver.Client(), delivery)
+
+		state := getWebhookDelivery(t, app)
+
+		if state.lastError == "" {
+			t.Errorf("attempt %d: last_error is empty", attempt)
+		}
+		if state.delivered {
+			t.Errorf("attempt %d: marked delivered", attempt)
+		}
+
+		if attempt < 3 {
+			if state.failed {
+				t.Fatalf("attempt %d: gave up before the max attempts", attempt)
+			}
+			if state.due {
+				t.Fatalf("attempt %d: retry is due right away instead of after the backoff", attempt)
+			}
+
+			// Skip the backoff.
+			_, err := db.Exec("UPDATE webhook_deliveries SET next_attempt_at = NOW()")
+			if err != nil {
+				t.Fatal(err)
+			}
+		} else if !state.failed {
+			t.Fatal("didn't give up after the max attempts")
+		}
+	}
+
+	if n := hits.Load(); n != 3 {
+		t.Errorf("receiver got %d requests, want 3", n)
+	}
+
+	deliveries, err := app.models.Webhooks.ClaimDeliveries(context.Background(), 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 0 {
+		t.Errorf("claimed %d deliveries after giving up, want 0", len(deliveries))
+	}
+}
+
+func TestDeliverWebhookMarksDelivered(t *testing.T) {
+	db := testdb.Open(t)
+	app := newTestApplication(t, db)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	enqueueWebhookDelivery(t, app, receiver.URL)
+
+	app.deliverWebhook(context.Background(), receiver.Client(), claimWebhookDelivery(t, app))
+
+	state := getWebhookDelivery(t, app)
+	if !state.delivered || state.failed || state.attempts != 1 {
+		t.Errorf("delivery = %+v, want delivered after 1 attempt", state)
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/skraio/banner-service/client"
+)
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// isSet reports whether the flag was given on the command line, so that
+// flags only override the fields they name.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// readInput decodes the JSON given with -data or -f into dst. It does
+// nothing when neither is set.
+func readInput(raw, file string, dst any) error {
+	if raw != "" && file != "" {
+		return errors.New("-data and -f are mutually exclusive")
+	}
+
+	var body []byte
+
+	switch {
+	case raw != "":
+		body = []byte(raw)
+	case file == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		body = b
+	case file != "":
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		body = b
+	default:
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return fmt.Errorf("invalid JSON input: %w", err)
+	}
+
+	return nil
+}
+
+func parseIDs(s string) ([]int64, error) {
+	if strings.TrimSpace(s) == "" {
+		return []int64{}, nil
+	}
+
+	parts := strings.Split(s, ",")
+	ids := make([]int64, 0, len(parts))
+
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", part)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (c *cli) login(ctx context.Context, args []string) error {
+	fs := newFlagSet("login")
+	username := fs.String("username", "", "Username")
+	password := fs.String("password", os.Getenv("BANNERCTL_PASSWORD"), "Password")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.client.CreateToken(ctx, *username, *password)
+	if err != nil {
+		return err
+	}
+
+	path, err := writeToken(token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "token saved to %s\n", path)
+
+	return nil
+}
+
+func (c *cli) banner(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("banner: expected list, get, create, update or delete")
+	}
+
+	switch args[0] {
+	case "list":
+		return c.bannerList(ctx, args[1:])
+	case "get":
+		return c.bannerGet(ctx, args[1:])
+	case "create":
+		return c.bannerCreate(ctx, args[1:])
+	case "update":
+		return c.bannerUpdate(ctx, args[1:])
+	case "delete":
+		return c.bannerDelete(ctx, args[1:])
+	default:
+		return fmt.Errorf("banner: unknown command %q", args[0])
+	}
+}
+
+func (c *cli) bannerList(ctx context.Context, args []string) error {
+	var filter client.BannerFilter
+
+	fs := newFlagSet("banner list")
+	fs.Int64Var(&filter.FeatureID, "feature", 0, "Feature id")
+	fs.Int64Var(&filter.TagID, "tag", 0, "Tag id")
+	fs.IntVar(&filter.Limit, "limit", 0, "Maximum number of banners")
+	fs.IntVar(&filter.Offset, "offset", 0, "Number of banners to skip")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	banners, metadata, err := c.client.ListBanners(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(map[string]any{"metadata": metadata, "banners": banners})
+	}
+
+	return printBanners(banners)
+}
+
+func (c *cli) bannerGet(ctx context.Context, args []string) error {
+	fs := newFlagSet("banner get")
+	featureID := fs.Int64("feature", 0, "Feature id")
+	tagID := fs.Int64("tag", 0, "Tag id")
+	last := fs.Bool("last", false, "Skip the cache and read the latest revision")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	content, err := c.client.GetUserBanner(ctx, *featureID, *tagID, *last)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(content)
+	}
+
+	return printContent(content)
+}
+
+type bannerFlags struct {
+	featureID int64
+	tags      string
+	title     string
+	text      string
+	url       string
+	active    bool
+	data      string
+	file      string
+}
+
+func (f *bannerFlags) register(fs *flag.FlagSet) {
+	fs.Int64Var(&f.featureID, "feature", 0, "Feature id")
+	fs.StringVar(&f.tags, "tags", "", "Comma separated tag ids")
+	fs.StringVar(&f.title, "title", "", "Content title")
+	fs.StringVar(&f.text, "text", "", "Content text")
+	fs.StringVar(&f.url, "url", "", "Content URL")
+	fs.BoolVar(&f.active, "active", false, "Whether the banner is active")
+	fs.StringVar(&f.data, "data", "", "Request body as JSON")
+	fs.StringVar(&f.file, "f", "", "File with the request body as JSON, - for stdin")
+}
+
+func (c *cli) bannerCreate(ctx context.Context, args []string) error {
+	var f bannerFlags
+
+	fs := newFlagSet("banner create")
+	f.register(fs)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var input client.CreateBannerInput
+
+	err = readInput(f.data, f.file, &input)
+	if err != nil {
+		return err
+	}
+
+	if isSet(fs, "feature") {
+		input.FeatureID = f.featureID
+	}
+	if isSet(fs, "tags") {
+		input.TagIDs, err = parseIDs(f.tags)
+		if err != nil {
+			return err
+		}
+	}
+	if isSet(fs, "title") {
+		input.Content.Title = f.title
+	}
+	if isSet(fs, "text") {
+		input.Content.Text = f.text
+	}
+	if isSet(fs, "url") {
+		input.Content.URL = f.url
+	}
+	if isSet(fs, "active") {
+		input.IsActive = f.active
+	}
+
+	id, err := c.client.CreateBanner(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(map[string]int64{"banner_id": id})
+	}
+
+	fmt.Println(id)
+
+	return nil
+}
+
+func (c *cli) bannerUpdate(ctx context.Context, args []string) error {
+	var f bannerFlags
+
+	fs := newFlagSet("banner update")
+	id := fs.Int64("id", 0, "Banner id")
+	f.register(fs)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == 0 {
+		return errors.New("banner update: -id is required")
+	}
+
+	var input client.UpdateBannerInput
+
+	err = readInput(f.data, f.file, &input)
+	if err != nil {
+		return err
+	}
+
+	if isSet(fs, "feature") {
+		input.FeatureID = &f.featureID
+	}
+	if isSet(fs, "tags") {
+		input.TagIDs, err = parseIDs(f.tags)
+		if err != nil {
+			return err
+		}
+	}
+	if isSet(fs, "title") || isSet(fs, "text") || isSet(fs, "url") {
+		if input.Content == nil {
+			input.Content = &client.Content{}
+		}
+		if isSet(fs, "title") {
+			input.Content.Title = f.title
+		}
+		if isSet(fs, "text") {
+			input.Content.Text = f.text
+		}
+		if isSet(fs, "url") {
+			input.Content.URL = f.url
+		}
+	}
+	if isSet(fs, "active") {
+		input.IsActive = &f.active
+	}
+
+	banner, err := c.client.UpdateBanner(ctx, *id, input)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(banner)
+	}
+
+	return printBanners([]*client.Banner{banner})
+}
+
+func (c *cli) bannerDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("banner delete")
+	id := fs.Int64("id", 0, "Banner id")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == 0 {
+		return errors.New("banner delete: -id is required")
+	}
+
+	return c.client.DeleteBanner(ctx, *id)
+}
+
+func (c *cli) user(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("user: expected list, get, create, update or delete")
+	}
+
+	switch args[0] {
+	case "list":
+		return c.userList(ctx, args[1:])
+	case "get":
+		return c.userGet(ctx, args[1:])
+	case "create":
+		return c.userCreate(ctx, args[1:])
+	case "update":
+		return c.userUpdate(ctx, args[1:])
+	case "delete":
+		return c.userDelete(ctx, args[1:])
+	default:
+		return fmt.Errorf("user: unknown command %q", args[0])
+	}
+}
+
+func (c *cli) userList(ctx context.Context, args []string) error {
+	var filter client.UserFilter
+
+	fs := newFlagSet("user list")
+	fs.StringVar(&filter.Role, "role", "", "Role (user|admin)")
+	fs.IntVar(&filter.Limit, "limit", 0, "Maximum number of users")
+	fs.IntVar(&filter.Offset, "offset", 0, "Number of users to skip")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	users, metadata, err := c.client.ListUsers(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(map[string]any{"metadata": metadata, "users": users})
+	}
+
+	return printUsers(users)
+}
+
+func (c *cli) userGet(ctx context.Context, args []string) error {
+	fs := newFlagSet("user get")
+	id := fs.Int64("id", 0, "User id")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == 0 {
+		return errors.New("user get: -id is required")
+	}
+
+	user, err := c.client.GetUser(ctx, *id)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(user)
+	}
+
+	return printUsers([]*client.User{user})
+}
+
+func (c *cli) userUpdate(ctx context.Context, args []string) error {
+	var input client.UpdateUserInput
+
+	fs := newFlagSet("user update")
+	id := fs.Int64("id", 0, "User id")
+	username := fs.String("username", "", "Username")
+	role := fs.String("role", "", "Role (user|admin)")
+	password := fs.String("password", "", "Password")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == 0 {
+		return errors.New("user update: -id is required")
+	}
+
+	if isSet(fs, "username") {
+		input.UserName = username
+	}
+	if isSet(fs, "role") {
+		input.Role = role
+	}
+	if isSet(fs, "password") {
+		input.Password = password
+	}
+
+	user, err := c.client.UpdateUser(ctx, *id, input)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(user)
+	}
+
+	return printUsers([]*client.User{user})
+}
+
+func (c *cli) userDelete(ctx context.Context, args []string) error {
+	fs := newFlagSet("user delete")
+	id := fs.Int64("id", 0, "User id")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if *id == 0 {
+		return errors.New("user delete: -id is required")
+	}
+
+	return c.client.DeleteUser(ctx, *id)
+}
+
+func (c *cli) userCreate(ctx context.Context, args []string) error {
+	var input client.CreateUserInput
+
+	fs := newFlagSet("user create")
+	username := fs.String("username", "", "Username")
+	role := fs.String("role", "user", "Role (user|admin)")
+	password := fs.String("password", "", "Password")
+	raw := fs.String("data", "", "Request body as JSON")
+	file := fs.String("f", "", "File with the request body as JSON, - for stdin")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	err = readInput(*raw, *file, &input)
+	if err != nil {
+		return err
+	}
+
+	if isSet(fs, "username") {
+		input.UserName = *username
+	}
+	if isSet(fs, "role") || input.Role == "" {
+		input.Role = *role
+	}
+	if isSet(fs, "password") {
+		input.Password = *password
+	}
+
+	user, token, err := c.client.CreateUser(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	if c.cfg.output == "json" {
+		return printJSON(map[string]any{"user": user, "token": token})
+	}
+
+	return printUser(user, token)
+}
+
+// passwd changes the password of the logged in user and caches the new
+// token, since the change revokes the old one.
+func (c *cli) passwd(ctx context.Context, args []string) error {
+	fs := newFlagSet("passwd")
+	current := fs.String("current", os.Getenv("BANNERCTL_PASSWORD"), "Current password")
+	password := fs.String("password", "", "New password")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.client.ChangePassword(ctx, *current, *password)
+	if err != nil {
+		return err
+	}
+
+	path, err := writeToken(token)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "password changed, new token saved to %s\n", path)
+
+	return nil
+}
+
+func (c *cli) token(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return errors.New("token: expected revoke")
+	}
+
+	err := c.client.RevokeTokens(ctx)
+	if err != nil {
+		return err
+	}
+
+	return removeToken()
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/skraio/banner-service/client"
+)
+
+const usage = `Usage: bannerctl [flags] <command> [arguments]
+
+Commands:
+  login -username <name> -password <password>
+  banner list [-feature id] [-tag id] [-limit n] [-offset n]
+  banner get -feature id -tag id [-last]
+  banner create [-feature id] [-tags 1,2] [-title ...] [-text ...] [-url ...] [-active] [-data json | -f file]
+  banner update -id id [-feature id] [-tags 1,2] [-title ...] [-text ...] [-url ...] [-active=bool] [-data json | -f file]
+  banner delete -id id
+  user list [-role user|admin] [-limit n] [-offset n]
+  user get -id id
+  user create -username <name> -role <user|admin> -password <password> [-data json | -f file]
+  user update -id id [-username <name>] [-role <user|admin>] [-password <password>]
+  user delete -id id
+  passwd -current <password> -password <new password>
+  token revoke
+
+Flags:
+`
+
+type config struct {
+	url     string
+	token   string
+	output  string
+	timeout time.Duration
+	tls     struct {
+		caFile   string
+		certFile string
+		keyFile  string
+	}
+}
+
+type cli struct {
+	cfg    config
+	client *client.Client
+}
+
+func main() {
+	err := run(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bannerctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	var cfg config
+
+	fs := flag.NewFlagSet("bannerctl", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	fs.StringVar(&cfg.url, "url", envOr("BANNERCTL_URL", "http://localhost:8080"), "API server URL")
+	fs.StringVar(&cfg.token, "token", os.Getenv("BANNERCTL_TOKEN"), "Bearer token, the cached login token by default")
+	fs.StringVar(&cfg.output, "o", "table", "Output format (table|json)")
+	fs.DurationVar(&cfg.timeout, "timeout", 10*time.Second, "Request timeout")
+	fs.StringVar(&cfg.tls.caFile, "tls-ca-file", os.Getenv("BANNERCTL_TLS_CA_FILE"), "CA bundle to verify the server with, the system roots by default")
+	fs.StringVar(&cfg.tls.certFile, "tls-cert-file", os.Getenv("BANNERCTL_TLS_CERT_FILE"), "Client certificate for admin routes behind mTLS")
+	fs.StringVar(&cfg.tls.keyFile, "tls-key-file", os.Getenv("BANNERCTL_TLS_KEY_FILE"), "Client certificate private key")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.output != "table" && cfg.output != "json" {
+		return fmt.Errorf("unknown output format %q", cfg.output)
+	}
+
+	if cfg.token == "" {
+		cfg.token, err = readToken()
+		if err != nil {
+			return err
+		}
+	}
+
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	c := &cli{
+		cfg:    cfg,
+		client: client.New(cfg.url, client.WithToken(cfg.token), client.WithHTTPClient(httpClient)),
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return errors.New("no command given")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.timeout)
+	defer cancel()
+
+	switch rest[0] {
+	case "login":
+		return c.login(ctx, rest[1:])
+	case "banner":
+		return c.banner(ctx, rest[1:])
+	case "user":
+		return c.user(ctx, rest[1:])
+	case "passwd":
+		return c.passwd(ctx, rest[1:])
+	case "token":
+		return c.token(ctx, rest[1:])
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", rest[0])
+	}
+}
+
+func newHTTPClient(cfg config) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.tls.caFile != "" {
+		pem, err := os.ReadFile(cfg.tls.caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.tls.caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.tls.certFile != "" || cfg.tls.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.tls.certFile, cfg.tls.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{Transport: transport}, nil
+}
+
+func envOr(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func tokenPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bannerctl", "token"), nil
+}
+
+// readToken returns the token cached by login, or an empty string when
+// there is none.
+func readToken() (string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", nil
+	}
+
+	token, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
+
+func writeToken(token string) (string, error) {
+	path, err := tokenPath()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return "", err
+	}
+
+	return path, os.WriteFile(path, []byte(token+"\n"), 0o600)
+}
+
+func removeToken() error {
+	path, err := tokenPath()
+	if err != nil {
+		return nil
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
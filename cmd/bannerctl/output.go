@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/skraio/banner-service/client"
+)
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
+}
+
+func printBanners(banners []*client.Banner) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tFEATURE\tTAGS\tACTIVE\tTITLE\tUPDATED")
+
+	for _, banner := range banners {
+		tags := make([]string, len(banner.TagIDs))
+		for i, tagID := range banner.TagIDs {
+			tags[i] = strconv.FormatInt(tagID, 10)
+		}
+
+		fmt.Fprintf(tw, "%d\t%d\t%s\t%t\t%s\t%s\n",
+			banner.BannerID,
+			banner.FeatureID,
+			strings.Join(tags, ","),
+			banner.IsActive,
+			banner.Content.Title,
+			banner.UpdatedAt.Format(time.RFC3339),
+		)
+	}
+
+	return tw.Flush()
+}
+
+func printContent(content *client.Content) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "TITLE\t%s\n", content.Title)
+	fmt.Fprintf(tw, "TEXT\t%s\n", content.Text)
+	fmt.Fprintf(tw, "URL\t%s\n", content.URL)
+
+	return tw.Flush()
+}
+
+func printUser(user *client.User, token string) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tUSERNAME\tROLE\tTOKEN")
+	fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", user.UserID, user.UserName, user.Role, token)
+
+	return tw.Flush()
+}
+
+func printUsers(users []*client.User) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tUSERNAME\tROLE\tCREATED")
+
+	for _, user := range users {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", user.UserID, user.UserName, user.Role, user.CreatedAt.Format(time.RFC3339))
+	}
+
+	return tw.Flush()
+}
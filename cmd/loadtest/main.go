@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type config struct {
+	url         string
+	requests    string
+	token       string
+	rps         float64
+	concurrency int
+	duration    time.Duration
+	total       int
+	timeout     time.Duration
+	seed        int64
+	synthetic   struct {
+		features int
+		tags     int
+	}
+	sli struct {
+		p99         time.Duration
+		successRate float64
+	}
+}
+
+// request is one line of the replay file, e.g.
+// {"method":"GET","path":"/user_banner?tag_id=1&feature_id=2","expect":[200,404]}.
+// Expect lists the statuses that count as a success, any 2xx or 3xx when
+// empty.
+type request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+	Expect  []int             `json:"expect"`
+}
+
+func (r request) expects(status int) bool {
+	if len(r.Expect) == 0 {
+		return status >= 200 && status < 400
+	}
+
+	return slices.Contains(r.Expect, status)
+}
+
+func main() {
+	var cfg config
+
+	flag.StringVar(&cfg.url, "url", "http://localhost:8080", "Base URL of the API server")
+	flag.StringVar(&cfg.requests, "requests", "", "JSONL file of requests to replay in a loop (synthetic GET /user_banner requests when empty)")
+	flag.StringVar(&cfg.token, "token", os.Getenv("LOADTEST_TOKEN"), "Bearer token sent with requests that don't set Authorization")
+	flag.Float64Var(&cfg.rps, "rps", 100, "Target requests per second")
+	flag.IntVar(&cfg.concurrency, "concurrency", 10, "Number of concurrent clients")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "Test duration")
+	flag.IntVar(&cfg.total, "n", 0, "Stop after this many requests (0 runs for -duration)")
+	flag.DurationVar(&cfg.timeout, "timeout", 5*time.Second, "Request timeout")
+	flag.Int64Var(&cfg.seed, "seed", 1, "Seed for synthetic requests")
+
+	flag.IntVar(&cfg.synthetic.features, "synthetic-features", 100, "Number of feature ids in synthetic requests")
+	flag.IntVar(&cfg.synthetic.tags, "synthetic-tags", 100, "Number of tag ids in synthetic requests")
+
+	flag.DurationVar(&cfg.sli.p99, "sli-p99", 50*time.Millisecond, "p99 latency target")
+	flag.Float64Var(&cfg.sli.successRate, "sli-success-rate", 99.99, "Success rate target in percent")
+
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	next, err := newRequestSource(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	report := run(cfg, next)
+
+	report.print(os.Stdout)
+
+	if !report.meets(cfg) {
+		logger.Error("SLI targets missed", "p99_target", cfg.sli.p99, "success_rate_target", cfg.sli.successRate)
+		os.Exit(1)
+	}
+}
+
+// newRequestSource returns a function producing the next request to send.
+// Replayed files wrap around when they run out.
+func newRequestSource(cfg config) (func() request, error) {
+	if cfg.requests == "" {
+		rng := rand.New(rand.NewSource(cfg.seed))
+
+		return func() request {
+			featureID := rng.Intn(cfg.synthetic.features) + 1
+			tagID := rng.Intn(cfg.synthetic.tags) + 1
+
+			// Most random pairs have no banner, so a 404 is a valid answer.
+			return request{
+				Method: http.MethodGet,
+				Path:   fmt.Sprintf("/user_banner?tag_id=%d&feature_id=%d", tagID, featureID),
+				Expect: []int{http.StatusOK, http.StatusNotFound},
+			}
+		}, nil
+	}
+
+	requests, err := readRequests(cfg.requests)
+	if err != nil {
+		return nil, err
+	}
+
+	i := 0
+
+	return func() request {
+		req := requests[i%len(requests)]
+		i++
+		return req
+	}, nil
+}
+
+func readRequests(path string) ([]request, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var requests []request
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var req request
+
+		err := json.Unmarshal(raw, &req)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+
+		if req.Method == "" || !strings.HasPrefix(req.Path, "/") {
+			return nil, fmt.Errorf("%s:%d: request must have a method and a path starting with /", path, line)
+		}
+
+		requests = append(requests, req)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(requests) == 0 {
+		return nil, errors.New("no requests to replay")
+	}
+
+	return requests, nil
+}
+
+func run(cfg config, next func() request) *report {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.duration)
+	defer cancel()
+
+	client := &http.Client{
+		Timeout: cfg.timeout,
+		Transport: &http.Transport{
+			MaxIdleConns:        cfg.concurrency,
+			MaxIdleConnsPerHost: cfg.concurrency,
+		},
+	}
+
+	limiter := rate.NewLimiter(rate.Limit(cfg.rps), 1)
+
+	jobs := make(chan request)
+	results := newReport()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for req := range jobs {
+				status, latency, err := send(client, cfg, req)
+				results.record(status, req.expects(status), latency, err)
+			}
+		}()
+	}
+
+	start := time.Now()
+
+	for sent := 0; cfg.total == 0 || sent < cfg.total; sent++ {
+		if limiter.Wait(ctx) != nil {
+			break
+		}
+
+		select {
+		case jobs <- next():
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	results.elapsed = time.Since(start)
+	slices.Sort(results.latencies)
+
+	return results
+}
+
+func send(client *http.Client, cfg config, r request) (int, time.Duration, error) {
+	var body io.Reader
+	if len(r.Body) > 0 {
+		body = bytes.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequest(r.Method, strings.TrimSuffix(cfg.url, "/")+r.Path, body)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for key, value := range r.Headers {
+		req.Header.Set(key, value)
+	}
+
+	if req.Header.Get("Authorization") == "" && cfg.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.token)
+	}
+
+	start := time.Now()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	latency := time.Since(start)
+
+	return resp.StatusCode, latency, err
+}
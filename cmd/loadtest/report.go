@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// report collects the outcome of every request. A request succeeds when it
+// gets a response with a status the request expects, so 429s and unexpected
+// 4xx count as failures along with 5xx and transport errors.
+type report struct {
+	mu         sync.Mutex
+	latencies  []time.Duration
+	statuses   map[int]int
+	unexpected map[int]int
+	errors     map[string]int
+	elapsed    time.Duration
+}
+
+func newReport() *report {
+	return &report{
+		statuses:   make(map[int]int),
+		unexpected: make(map[int]int),
+		errors:     make(map[string]int),
+	}
+}
+
+func (r *report) record(status int, expected bool, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies = append(r.latencies, latency)
+
+	if err != nil {
+		r.errors[err.Error()]++
+		return
+	}
+
+	r.statuses[status]++
+	if !expected {
+		r.unexpected[status]++
+	}
+}
+
+func (r *report) total() int {
+	return len(r.latencies)
+}
+
+func (r *report) failed() int {
+	failed := 0
+
+	for _, count := range r.errors {
+		failed += count
+	}
+
+	for _, count := range r.unexpected {
+		failed += count
+	}
+
+	return failed
+}
+
+// failedWith counts the unexpected responses with a status in [from, to].
+func (r *report) failedWith(from, to int) int {
+	failed := 0
+
+	for status, count := range r.unexpected {
+		if status >= from && status <= to {
+			failed += count
+		}
+	}
+
+	return failed
+}
+
+func (r *report) successRate() float64 {
+	if r.total() == 0 {
+		return 0
+	}
+
+	return 100 * float64(r.total()-r.failed()) / float64(r.total())
+}
+
+// percentile uses the nearest-rank method, so the latencies must be sorted.
+func (r *report) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	rank := int(math.Ceil(p / 100 * float64(len(r.latencies))))
+	rank = min(max(rank, 1), len(r.latencies))
+
+	return r.latencies[rank-1]
+}
+
+func (r *report) meets(cfg config) bool {
+	return r.total() > 0 && r.percentile(99) <= cfg.sli.p99 && r.successRate() >= cfg.sli.successRate
+}
+
+func (r *report) print(w io.Writer) {
+	fmt.Fprintf(w, "Summary:\n")
+	fmt.Fprintf(w, "  Total:\t%d requests in %s\n", r.total(), r.elapsed.Round(time.Millisecond))
+	if r.elapsed > 0 {
+		fmt.Fprintf(w, "  Requests/sec:\t%.2f\n", float64(r.total())/r.elapsed.Seconds())
+	}
+	fmt.Fprintf(w, "  Success rate:\t%.4f%%\n", r.successRate())
+	if r.total() > 0 {
+		fmt.Fprintf(w, "  Error rate:\t%.4f%%\n", 100-r.successRate())
+	}
+
+	if r.failed() > 0 {
+		transport := 0
+		for _, count := range r.errors {
+			transport += count
+		}
+
+		fmt.Fprintf(w, "\nFailures:\n")
+		fmt.Fprintf(w, "  Server errors (5xx):\t%d\n", r.failedWith(500, 599))
+		fmt.Fprintf(w, "  Throttled (429):\t%d\n", r.failedWith(429, 429))
+		fmt.Fprintf(w, "  Other unexpected:\t%d\n", r.failed()-transport-r.failedWith(500, 599)-r.failedWith(429, 429))
+		fmt.Fprintf(w, "  Transport errors:\t%d\n", transport)
+	}
+
+	fmt.Fprintf(w, "\nLatency distribution:\n")
+	for _, p := range []float64{50, 90, 95, 99, 99.9, 100} {
+		fmt.Fprintf(w, "  p%v:\t%s\n", p, r.percentile(p))
+	}
+
+	fmt.Fprintf(w, "\nStatus code distribution:\n")
+	statuses := make([]int, 0, len(r.statuses))
+	for status := range r.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		if n := r.unexpected[status]; n > 0 {
+			fmt.Fprintf(w, "  [%d]\t%d responses (%d unexpected)\n", status, r.statuses[status], n)
+			continue
+		}
+		fmt.Fprintf(w, "  [%d]\t%d responses\n", status, r.statuses[status])
+	}
+
+	if len(r.errors) > 0 {
+		fmt.Fprintf(w, "\nErrors:\n")
+		for msg, count := range r.errors {
+			fmt.Fprintf(w, "  %d\t%s\n", count, msg)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/skraio/banner-service/internal/data"
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+type config struct {
+	dsn         string
+	seed        int64
+	banners     int
+	features    int
+	tags        int
+	maxTags     int
+	activeRatio float64
+	password    string
+	admin       string
+	user        string
+}
+
+type pair struct {
+	featureID int64
+	tagID     int64
+}
+
+func main() {
+	var cfg config
+
+	flag.StringVar(&cfg.dsn, "db-dsn", os.Getenv("DB_DSN"), "PostgreSQL DSN")
+	flag.Int64Var(&cfg.seed, "seed", 1, "Random seed, the same seed generates the same banners into an empty table")
+	flag.IntVar(&cfg.banners, "banners", 1000, "Number of banners to generate")
+	flag.IntVar(&cfg.features, "features", 100, "Number of distinct feature ids")
+	flag.IntVar(&cfg.tags, "tags", 1000, "Number of distinct tag ids")
+	flag.IntVar(&cfg.maxTags, "max-tags", 5, "Maximum number of tags per banner")
+	flag.Float64Var(&cfg.activeRatio, "active-ratio", 0.9, "Share of active banners")
+	flag.StringVar(&cfg.password, "password", "strongpassword", "Password of the seeded users")
+	flag.StringVar(&cfg.admin, "admin", "admin01", "Username of the seeded admin")
+	flag.StringVar(&cfg.user, "user", "user01", "Username of the seeded user")
+
+	flag.Parse()
+
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	v := validator.New()
+
+	v.Check(cfg.banners >= 0, "banners", "must be non-negative")
+	v.Check(cfg.features > 0, "features", "must be greater than zero")
+	v.Check(cfg.tags > 0, "tags", "must be greater than zero")
+	v.Check(cfg.maxTags > 0, "max-tags", "must be greater than zero")
+	v.Check(cfg.maxTags <= 1000, "max-tags", "must not be more than 1000")
+	v.Check(cfg.activeRatio >= 0 && cfg.activeRatio <= 1, "active-ratio", "must be between 0 and 1")
+	data.ValidateUsername(v, cfg.admin)
+	data.ValidateUsername(v, cfg.user)
+	data.NewPasswordPolicy(data.DefaultPasswordMinLength).Validate(v, cfg.password)
+
+	if !v.Valid() {
+		for key, message := range v.Errors {
+			logger.Error(fmt.Sprintf("%s %s", key, message))
+		}
+		os.Exit(1)
+	}
+
+	db, err := openDB(cfg)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	models := data.NewModels(db, nil, data.DefaultTimeouts)
+
+	for _, u := range []struct {
+		name string
+		role data.Role
+	}{{cfg.admin, data.RoleAdmin}, {cfg.user, data.RoleUser}} {
+		token, err := seedUser(models, u.name, u.role, cfg.password)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s\t%s\t%s\n", u.role, u.name, token.Plaintext)
+	}
+
+	used, err := existingPairs(db)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	// Generation skips the pairs already taken, so a seed only reproduces
+	// the same banners when the table starts empty.
+	if len(used) > 0 {
+		logger.Warn("banners table is not empty, the seeded banners depend on its contents", "seed", cfg.seed)
+	}
+
+	banners := generateBanners(cfg, used)
+	if len(banners) < cfg.banners {
+		logger.Warn("not enough free feature and tag pairs", "requested", cfg.banners, "generated", len(banners))
+	}
+
+	start := time.Now()
+
+	err = copyBanners(db, banners)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	logger.Info("banners seeded", "count", len(banners), "duration", time.Since(start))
+}
+
+func openDB(cfg config) (*sql.DB, error) {
+	db, err := sql.Open("pgx", cfg.dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = db.PingContext(ctx)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// seedUser creates the user, or reuses it when it already exists, and issues
+// a fresh token.
+func seedUser(models data.Models, username string, role data.Role, password string) (*data.Token, error) {
+	user := &data.User{
+		UserName: username,
+		Role:     role,
+	}
+
+	err := user.Password.Set(password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = models.Users.Insert(context.Background(), user, data.Actor{})
+	if err != nil {
+		if !errors.Is(err, data.ErrDuplicateUserName) {
+			return nil, err
+		}
+
+		user, err = models.Users.GetByUserName(context.Background(), username)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return models.Tokens.New(context.Background(), user.UserID)
+}
+
+func existingPairs(db *sql.DB) (map[pair]bool, error) {
+	query := `
+        SELECT feature_id, unnest(tag_ids)
+        FROM banners
+        WHERE deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	used := make(map[pair]bool)
+
+	for rows.Next() {
+		var p pair
+
+		err := rows.Scan(&p.featureID, &p.tagID)
+		if err != nil {
+			return nil, err
+		}
+
+		used[p] = true
+	}
+
+	return used, rows.Err()
+}
+
+// generateBanners picks tags from a Zipf distribution, so a few popular tags
+// are shared by many features. A feature and tag pair never appears in more
+// than one banner.
+func generateBanners(cfg config, used map[pair]bool) []*data.Banner {
+	rng := rand.New(rand.NewSource(cfg.seed))
+	zipf := rand.NewZipf(rng, 1.1, 1, uint64(cfg.tags-1))
+
+	banners := make([]*data.Banner, 0, cfg.banners)
+
+	misses := 0
+
+	for len(banners) < cfg.banners && misses < 100 {
+		featureID := int64(rng.Intn(cfg.features) + 1)
+		want := rng.Intn(cfg.maxTags) + 1
+
+		seen := make(map[int64]bool)
+		tagIDs := make([]int64, 0, want)
+
+		for attempt := 0; len(tagIDs) < want && attempt < 4*want; attempt++ {
+			tagID := int64(zipf.Uint64() + 1)
+
+			if seen[tagID] || used[pair{featureID, tagID}] {
+				continue
+			}
+
+			seen[tagID] = true
+			tagIDs = append(tagIDs, tagID)
+		}
+
+		if len(tagIDs) == 0 {
+			misses++
+			continue
+		}
+
+		misses = 0
+
+		for _, tagID := range tagIDs {
+			used[pair{featureID, tagID}] = true
+		}
+
+		n := len(banners) + 1
+
+		banners = append(banners, &data.Banner{
+			FeatureID: featureID,
+			TagIDs:    tagIDs,
+			Content: data.Content{
+				Title: fmt.Sprintf("banner %d", n),
+				Text:  fmt.Sprintf("seeded banner %d for feature %d", n, featureID),
+				URL:   fmt.Sprintf("https://example.com/banners/%d", n),
+			},
+			IsActive: rng.Float64() < cfg.activeRatio,
+		})
+	}
+
+	return banners
+}
+
+// copyBanners loads banners with COPY on a connection borrowed from the
+// pool. The banner triggers would log an event, enqueue webhook deliveries
+// and send a cache notification for every row, so the COPY runs as a replica
+// session with them switched off, which takes a superuser. Running servers
+// are told to flush their caches once instead.
+func copyBanners(db *sql.DB, banners []*data.Banner) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	rows := make([][]any, 0, len(banners))
+
+	for _, banner := range banners {
+		contentJSON, err := json.Marshal(banner.Content)
+		if err != nil {
+			return err
+		}
+
+		rows = append(rows, []any{banner.TagIDs, banner.FeatureID, string(contentJSON), banner.IsActive})
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+
+		tx, err := pgxConn.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+
+		_, err = tx.Exec(ctx, `SET LOCAL session_replication_role = replica`)
+		if err != nil {
+			return fmt.Errorf("disabling banner triggers: %w", err)
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"banners"}, []string{"tag_ids", "feature_id", "content", "is_active"}, pgx.CopyFromRows(rows))
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `SELECT pg_notify($1, 'flush')`, data.BannerCacheChannel)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
+}
@@ -0,0 +1,15 @@
+package cache
+
+import (
+	"time"
+)
+
+// Cache stores byte values under string keys for at most their ttl. Every
+// backend treats a missing and an expired key the same way: Get reports
+// ok == false. Setting a key with a non-positive ttl deletes it.
+type Cache interface {
+	Get(key string) (value []byte, ok bool, err error)
+	Set(key string, value []byte, ttl time.Duration) error
+	Delete(keys ...string) error
+	Flush() error
+}
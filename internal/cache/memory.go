@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+type item struct {
+	value   []byte
+	expires time.Time
+}
+
+// Memory is a process-local cache. Expired entries are skipped on read and
+// dropped by DeleteExpired.
+type Memory struct {
+	mu    sync.RWMutex
+	items map[string]item
+}
+
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]item)}
+}
+
+func (c *Memory) Get(key string) ([]byte, bool, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	it, ok := c.items[key]
+	if !ok || time.Now().After(it.expires) {
+		return nil, false, nil
+	}
+
+	return it.value, true, nil
+}
+
+func (c *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ttl <= 0 {
+		delete(c.items, key)
+		return nil
+	}
+
+	c.items[key] = item{value: value, expires: time.Now().Add(ttl)}
+
+	return nil
+}
+
+func (c *Memory) Delete(keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+
+	return nil
+}
+
+func (c *Memory) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]item)
+
+	return nil
+}
+
+func (c *Memory) DeleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	for key, it := range c.items {
+		if now.After(it.expires) {
+			delete(c.items, key)
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RESPError is an error reply sent by the server.
+type RESPError string
+
+func (e RESPError) Error() string {
+	return string(e)
+}
+
+type RESPOptions struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is the key prefix owned by this service. Flush only removes
+	// keys starting with it, so the server can be shared.
+	Prefix   string
+	PoolSize int
+	Timeout  time.Duration
+}
+
+// RESP is a cache backed by a server speaking the Redis protocol. Keys expire
+// on the server, which gives it the same TTL semantics as Memory.
+type RESP struct {
+	opts RESPOptions
+	pool chan *respConn
+}
+
+type respConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+	wr   *bufio.Writer
+}
+
+func NewRESP(opts RESPOptions) (*RESP, error) {
+	if opts.PoolSize < 1 {
+		opts.PoolSize = 1
+	}
+
+	c := &RESP{
+		opts: opts,
+		pool: make(chan *respConn, opts.PoolSize),
+	}
+
+	reply, err := c.do("PING")
+	if err != nil {
+		return nil, err
+	}
+
+	if reply != "PONG" {
+		return nil, fmt.Errorf("cache: unexpected PING reply %v", reply)
+	}
+
+	return c, nil
+}
+
+func (c *RESP) Get(key string) ([]byte, bool, error) {
+	reply, err := c.do("GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch reply := reply.(type) {
+	case nil:
+		return nil, false, nil
+	case []byte:
+		return reply, true, nil
+	default:
+		return nil, false, fmt.Errorf("cache: unexpected GET reply %v", reply)
+	}
+}
+
+func (c *RESP) Set(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return c.Delete(key)
+	}
+
+	ms := max(ttl.Milliseconds(), 1)
+
+	_, err := c.do("SET", key, string(value), "PX", strconv.FormatInt(ms, 10))
+	return err
+}
+
+func (c *RESP) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err := c.do(append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+func (c *RESP) Flush() error {
+	cursor := "0"
+
+	for {
+		reply, err := c.do("SCAN", cursor, "MATCH", c.opts.Prefix+"*", "COUNT", "500")
+		if err != nil {
+			return err
+		}
+
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 2 {
+			return fmt.Errorf("cache: unexpected SCAN reply %v", reply)
+		}
+
+		next, ok := parts[0].([]byte)
+		if !ok {
+			return fmt.Errorf("cache: unexpected SCAN cursor %v", parts[0])
+		}
+
+		found, _ := parts[1].([]any)
+
+		keys := make([]string, 0, len(found))
+		for _, key := range found {
+			if key, ok := key.([]byte); ok {
+				keys = append(keys, string(key))
+			}
+		}
+
+		err = c.Delete(keys...)
+		if err != nil {
+			return err
+		}
+
+		cursor = string(next)
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+func (c *RESP) Close() error {
+	for {
+		select {
+		case cn := <-c.pool:
+			cn.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *RESP) do(args ...string) (any, error) {
+	cn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a complete error reply leaves the connection ready for the next
+	// command. After any other error part of the reply may be unread.
+	reply, err := cn.roundTrip(c.opts.Timeout, args)
+	if err != nil {
+		var respError RESPError
+		if !errors.As(err, &respError) {
+			cn.conn.Close()
+			return nil, err
+		}
+	}
+
+	select {
+	case c.pool <- cn:
+	default:
+		cn.conn.Close()
+	}
+
+	return reply, err
+}
+
+func (c *RESP) conn() (*respConn, error) {
+	select {
+	case cn := <-c.pool:
+		return cn, nil
+	default:
+	}
+
+	conn, err := net.DialTimeout("tcp", c.opts.Addr, c.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &respConn{
+		conn: conn,
+		rd:   bufio.NewReader(conn),
+		wr:   bufio.NewWriter(conn),
+	}
+
+	if c.opts.Password != "" {
+		_, err = cn.roundTrip(c.opts.Timeout, []string{"AUTH", c.opts.Password})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if c.opts.DB != 0 {
+		_, err = cn.roundTrip(c.opts.Timeout, []string{"SELECT", strconv.Itoa(c.opts.DB)})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+func (cn *respConn) roundTrip(timeout time.Duration, args []string) (any, error) {
+	if timeout > 0 {
+		cn.conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	fmt.Fprintf(cn.wr, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(cn.wr, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	err := cn.wr.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	return readReply(cn.rd)
+}
+
+// readReply reads one reply. A top-level error reply is returned as a
+// RESPError, while error elements of an array are returned as RESPError
+// values, so that the rest of the array is still read.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("cache: malformed reply %q", line)
+	}
+
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RESPError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("cache: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		buf := make([]byte, n+2)
+		_, err = io.ReadFull(rd, buf)
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("cache: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+
+		items := make([]any, n)
+		for i := range items {
+			items[i], err = readReply(rd)
+
+			var respError RESPError
+			if errors.As(err, &respError) {
+				items[i] = respError
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("cache: unknown reply type %q", kind)
+	}
+}
@@ -0,0 +1,594 @@
+package cache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// respStub is an in-process server speaking enough of the Redis protocol for
+// RESP. A few keys misbehave on GET:
+//
+//	error    replies with -ERR
+//	nested   replies with an array holding an error element
+//	hangup   closes the connection without a reply
+//	garbage  replies with a line that isn't RESP
+//	slow     replies after a second
+type respStub struct {
+	ln       net.Listener
+	password string
+
+	mu    sync.Mutex
+	data  map[string][]byte
+	ttls  map[string]time.Time
+	calls []string
+
+	accepted atomic.Int64
+	open     atomic.Int64
+}
+
+func newRESPStub(t *testing.T, password string) *respStub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &respStub{
+		ln:       ln,
+		password: password,
+		data:     make(map[string][]byte),
+		ttls:     make(map[string]time.Time),
+	}
+
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+
+	return s
+}
+
+func (s *respStub) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *respStub) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		s.accepted.Add(1)
+		s.open.Add(1)
+
+		go func() {
+			defer s.open.Add(-1)
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *respStub) handle(conn net.Conn) {
+	rd := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.calls = append(s.calls, strings.Join(args, " "))
+		s.mu.Unlock()
+
+		if !authed && !strings.EqualFold(args[0], "AUTH") {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+
+		var reply string
+
+		switch strings.ToUpper(args[0]) {
+		case "PING":
+			reply = "+PONG\r\n"
+		case "AUTH":
+			if args[1] != s.password {
+				reply = "-WRONGPASS invalid password\r\n"
+				break
+			}
+			authed = true
+			reply = "+OK\r\n"
+		case "SELECT":
+			reply = "+OK\r\n"
+		case "GET":
+			switch args[1] {
+			case "error":
+				reply = "-ERR boom\r\n"
+			case "nested":
+				reply = "*3\r\n$1\r\na\r\n-ERR inner\r\n$1\r\nb\r\n"
+			case "hangup":
+				return
+			case "garbage":
+				reply = "?garbage\r\n"
+			case "slow":
+				time.Sleep(time.Second)
+				reply = "$-1\r\n"
+			default:
+				reply = bulk(s.get(args[1]))
+			}
+		case "SET":
+			reply = s.set(args[1:])
+		case "DEL":
+			reply = ":" + strconv.Itoa(s.del(args[1:])) + "\r\n"
+		case "SCAN":
+			reply = s.scan(args[1:])
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if line[0] != '*' || err != nil || n < 1 {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if line[0] != '$' || err != nil {
+			return nil, fmt.Errorf("bad argument %q", line)
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func bulk(value []byte) string {
+	if value == nil {
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func (s *respStub) get(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if expires, ok := s.ttls[key]; ok && time.Now().After(expires) {
+		delete(s.data, key)
+		delete(s.ttls, key)
+	}
+
+	return s.data[key]
+}
+
+func (s *respStub) set(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data[args[0]] = []byte(args[1])
+	delete(s.ttls, args[0])
+
+	if len(args) == 4 && strings.EqualFold(args[2], "PX") {
+		ms, err := strconv.Atoi(args[3])
+		if err != nil || ms <= 0 {
+			return "-ERR invalid expire time\r\n"
+		}
+		s.ttls[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	return "+OK\r\n"
+}
+
+func (s *respStub) del(keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, key := range keys {
+		if _, ok := s.data[key]; ok {
+			n++
+		}
+		delete(s.data, key)
+		delete(s.ttls, key)
+	}
+
+	return n
+}
+
+// scan pages through the matching keys two at a time. The cursor is the last
+// key returned, so that keys deleted between pages don't shift the rest.
+func (s *respStub) scan(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := strings.TrimSuffix(args[2], "*")
+
+	var keys []string
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) && (args[0] == "0" || key > args[0]) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	page := keys[:min(2, len(keys))]
+
+	next := "0"
+	if len(keys) > len(page) {
+		next = page[len(page)-1]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*2\r\n%s*%d\r\n", bulk([]byte(next)), len(page))
+	for _, key := range page {
+		b.WriteString(bulk([]byte(key)))
+	}
+
+	return b.String()
+}
+
+func (s *respStub) callsMatching(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var calls []string
+	for _, call := range s.calls {
+		if strings.HasPrefix(call, prefix) {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+func newTestRESP(t *testing.T, s *respStub, opts RESPOptions) *RESP {
+	t.Helper()
+
+	opts.Addr = s.addr()
+	if opts.Timeout == 0 {
+		opts.Timeout = 500 * time.Millisecond
+	}
+
+	c, err := NewRESP(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
+
+func TestCacheBackends(t *testing.T) {
+	backends := map[string]func(t *testing.T) Cache{
+		"memory": func(t *testing.T) Cache { return NewMemory() },
+		"resp": func(t *testing.T) Cache {
+			return newTestRESP(t, newRESPStub(t, ""), RESPOptions{Prefix: "banner:", PoolSize: 2})
+		},
+	}
+
+	for name, newCache := range backends {
+		t.Run(name, func(t *testing.T) {
+			c := newCache(t)
+
+			if _, ok, err := c.Get("banner:1:1"); ok || err != nil {
+				t.Fatalf("Get of a missing key = ok %t, err %v", ok, err)
+			}
+
+			if err := c.Set("banner:1:1", []byte(`{"banner_id":1}`), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+
+			value, ok, err := c.Get("banner:1:1")
+			if err != nil || !ok || string(value) != `{"banner_id":1}` {
+				t.Fatalf("Get = %q, ok %t, err %v", value, ok, err)
+			}
+
+			if err := c.Set("banner:1:2", []byte("null"), 50*time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if _, ok, _ := c.Get("banner:1:2"); ok {
+				t.Error("Get returned an expired key")
+			}
+
+			if err := c.Set("banner:1:1", []byte("x"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := c.Get("banner:1:1"); ok {
+				t.Error("Set with a zero ttl didn't delete the key")
+			}
+
+			for _, key := range []string{"banner:2:1", "banner:2:2", "banner:2:3"} {
+				if err := c.Set(key, []byte("v"), time.Minute); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := c.Delete("banner:2:1", "banner:2:2"); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Delete(); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, ok, _ := c.Get("banner:2:1"); ok {
+				t.Error("Delete left banner:2:1")
+			}
+			if _, ok, _ := c.Get("banner:2:3"); !ok {
+				t.Error("Delete removed banner:2:3")
+			}
+
+			if err := c.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, _ := c.Get("banner:2:3"); ok {
+				t.Error("Flush left banner:2:3")
+			}
+		})
+	}
+}
+
+func TestRESPFlushOnlyRemovesPrefixedKeys(t *testing.T) {
+	s := newRESPStub(t, "")
+	c := newTestRESP(t, s, RESPOptions{Prefix: "banner:"})
+
+	for i := 0; i < 5; i++ {
+		if err := c.Set(fmt.Sprintf("banner:%d:1", i), []byte("v"), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.set([]string{"other:1", "v"})
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if s.get(fmt.Sprintf("banner:%d:1", i)) != nil {
+			t.Errorf("Flush left banner:%d:1", i)
+		}
+	}
+	if s.get("other:1") == nil {
+		t.Error("Flush removed a key outside the prefix")
+	}
+
+	// Five keys take three pages of two.
+	if scans := s.callsMatching("SCAN"); len(scans) != 3 {
+		t.Errorf("Flush sent %d SCANs, want 3: %q", len(scans), scans)
+	}
+}
+
+func TestRESPSetSendsTTLInMilliseconds(t *testing.T) {
+	s := newRESPStub(t, "")
+	c := newTestRESP(t, s, RESPOptions{})
+
+	if err := c.Set("k", []byte("v"), 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("k", []byte("v"), time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+
+	sets := s.callsMatching("SET")
+	want := []string{"SET k v PX 1500", "SET k v PX 1"}
+
+	if strings.Join(sets, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", sets, want)
+	}
+}
+
+func TestRESPAuthAndSelect(t *testing.T) {
+	s := newRESPStub(t, "secret")
+
+	newTestRESP(t, s, RESPOptions{Password: "secret", DB: 3})
+
+	if auth := s.callsMatching("AUTH"); len(auth) != 1 || auth[0] != "AUTH secret" {
+		t.Errorf("sent %q, want one AUTH secret", auth)
+	}
+	if sel := s.callsMatching("SELECT"); len(sel) != 1 || sel[0] != "SELECT 3" {
+		t.Errorf("sent %q, want one SELECT 3", sel)
+	}
+
+	_, err := NewRESP(RESPOptions{Addr: s.addr(), Password: "wrong", Timeout: time.Second})
+
+	var respError RESPError
+	if !errors.As(err, &respError) {
+		t.Errorf("NewRESP with a wrong password = %v, want a RESPError", err)
+	}
+}
+
+func TestRESPReusesPooledConnections(t *testing.T) {
+	s := newRESPStub(t, "")
+	c := newTestRESP(t, s, RESPOptions{PoolSize: 2})
+
+	for i := 0; i < 10; i++ {
+		if _, _, err := c.Get("k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := s.accepted.Load(); n != 1 {
+		t.Errorf("opened %d connections for sequential commands, want 1", n)
+	}
+
+	// Five concurrent slow commands need five connections, of which the pool
+	// keeps two.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			slow := newTestRESPConnOptions(c, 2*time.Second)
+			if _, _, err := slow.Get("slow"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitFor(t, func() bool { return s.open.Load() == 2 })
+
+	accepted := s.accepted.Load()
+
+	for i := 0; i < 10; i++ {
+		if _, _, err := c.Get("k"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := s.accepted.Load(); n != accepted {
+		t.Errorf("opened %d more connections with a warm pool, want 0", n-accepted)
+	}
+}
+
+// newTestRESPConnOptions returns a client sharing c's pool with another
+// timeout.
+func newTestRESPConnOptions(c *RESP, timeout time.Duration) *RESP {
+	opts := c.opts
+	opts.Timeout = timeout
+	return &RESP{opts: opts, pool: c.pool}
+}
+
+func TestRESPErrorReplyKeepsTheConnection(t *testing.T) {
+	s := newRESPStub(t, "")
+	c := newTestRESP(t, s, RESPOptions{PoolSize: 1})
+
+	_, _, err := c.Get("error")
+
+	var respError RESPError
+	if !errors.As(err, &respError) || string(respError) != "ERR boom" {
+		t.Fatalf("Get = %v, want RESPError ERR boom", err)
+	}
+
+	if err := c.Set("k", []byte("v"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := c.Get("k")
+	if err != nil || !ok || string(value) != "v" {
+		t.Fatalf("Get after an error reply = %q, ok %t, err %v", value, ok, err)
+	}
+
+	if n := s.accepted.Load(); n != 1 {
+		t.Errorf("opened %d connections, want 1", n)
+	}
+}
+
+func TestRESPNestedErrorDoesNotDesync(t *testing.T) {
+	s := newRESPStub(t, "")
+	c := newTestRESP(t, s, RESPOptions{PoolSize: 1})
+
+	reply, err := c.do("GET", "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, ok := reply.([]any)
+	if !ok || len(items) != 3 {
+		t.Fatalf("reply = %#v, want an array of 3", reply)
+	}
+	if items[1] != RESPError("ERR inner") || string(items[2].([]byte)) != "b" {
+		t.Errorf("reply = %#v", items)
+	}
+
+	if err := c.Set("k", []byte("v"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := c.Get("k")
+	if err != nil || !ok || string(value) != "v" {
+		t.Fatalf("Get after a nested error = %q, ok %t, err %v", value, ok, err)
+	}
+}
+
+func TestRESPBrokenRepliesCloseTheConnection(t *testing.T) {
+	for _, key := range []string{"hangup", "garbage", "slow"} {
+		t.Run(key, func(t *testing.T) {
+			s := newRESPStub(t, "")
+			c := newTestRESP(t, s, RESPOptions{PoolSize: 1, Timeout: 200 * time.Millisecond})
+
+			_, _, err := c.Get(key)
+			if err == nil {
+				t.Fatal("Get succeeded")
+			}
+
+			var respError RESPError
+			if errors.As(err, &respError) {
+				t.Fatalf("Get = %v, want a connection error", err)
+			}
+
+			// The next command runs on a new connection.
+			if err := c.Set("k", []byte("v"), time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			value, ok, err := c.Get("k")
+			if err != nil || !ok || string(value) != "v" {
+				t.Fatalf("Get after a broken reply = %q, ok %t, err %v", value, ok, err)
+			}
+
+			if n := s.accepted.Load(); n != 2 {
+				t.Errorf("opened %d connections, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRESPDialFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	_, err = NewRESP(RESPOptions{Addr: addr, Timeout: 200 * time.Millisecond})
+	if err == nil {
+		t.Error("NewRESP succeeded without a server")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
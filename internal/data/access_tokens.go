@@ -0,0 +1,274 @@
+package data
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+
+	// minHMACKeyLength is the shortest HS256 key accepted, the size of the
+	// hash.
+	minHMACKeyLength = sha256.Size
+
+	accessTokenIssuer = "banner-service"
+)
+
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+var b64 = base64.RawURLEncoding
+
+// SigningKey signs and verifies access tokens. ID is sent in the kid header,
+// so that tokens signed with a retired key can still be verified.
+type SigningKey struct {
+	ID  string
+	Alg string
+
+	secret     []byte
+	privateKey ed25519.PrivateKey
+}
+
+// ParseSigningKey parses a key given as kid:alg:key, where alg is HS256 or
+// EdDSA and key is base64. An HS256 key is a secret of at least 32 bytes,
+// an EdDSA key is a 32-byte Ed25519 seed.
+func ParseSigningKey(spec string) (SigningKey, error) {
+	parts := strings.SplitN(spec, ":", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return SigningKey{}, errors.New("must be kid:alg:key")
+	}
+
+	key := SigningKey{ID: parts[0], Alg: parts[1]}
+
+	raw, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("key %s: must be base64", key.ID)
+	}
+
+	switch key.Alg {
+	case AlgHS256:
+		if len(raw) < minHMACKeyLength {
+			return SigningKey{}, fmt.Errorf("key %s: must be at least %d bytes long", key.ID, minHMACKeyLength)
+		}
+		key.secret = raw
+	case AlgEdDSA:
+		if len(raw) != ed25519.SeedSize {
+			return SigningKey{}, fmt.Errorf("key %s: must be a %d-byte Ed25519 seed", key.ID, ed25519.SeedSize)
+		}
+		key.privateKey = ed25519.NewKeyFromSeed(raw)
+	default:
+		return SigningKey{}, fmt.Errorf("key %s: algorithm must be %s or %s", key.ID, AlgHS256, AlgEdDSA)
+	}
+
+	return key, nil
+}
+
+func (k SigningKey) sign(message []byte) []byte {
+	if k.Alg == AlgEdDSA {
+		return ed25519.Sign(k.privateKey, message)
+	}
+
+	mac := hmac.New(sha256.New, k.secret)
+	mac.Write(message)
+	return mac.Sum(nil)
+}
+
+func (k SigningKey) verify(message, signature []byte) bool {
+	if k.Alg == AlgEdDSA {
+		return ed25519.Verify(k.privateKey.Public().(ed25519.PublicKey), message, signature)
+	}
+
+	return hmac.Equal(k.sign(message), signature)
+}
+
+// AccessToken is a short-lived JWT that carries the user's ID and role, so
+// that it is verified without a database round trip. Revocations reach
+// every instance through the token_revocations table and its NOTIFY.
+type AccessToken struct {
+	Plaintext string    `json:"token"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+type accessTokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type accessTokenClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Role      Role   `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// AccessTokens issues access tokens with the first key and verifies them
+// with any of the keys. To rotate, put the new key first and drop the old
+// one once the tokens it signed have expired.
+type AccessTokens struct {
+	ttl     time.Duration
+	current SigningKey
+	keys    map[string]SigningKey
+
+	mu sync.RWMutex
+	// revoked maps a user ID to the Unix time before which the user's
+	// tokens were revoked.
+	revoked map[int64]int64
+}
+
+func NewAccessTokens(ttl time.Duration, keys ...SigningKey) (*AccessTokens, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	a := &AccessTokens{
+		ttl:     ttl,
+		current: keys[0],
+		keys:    make(map[string]SigningKey, len(keys)),
+		revoked: make(map[int64]int64),
+	}
+
+	for _, key := range keys {
+		if _, ok := a.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate signing key %s", key.ID)
+		}
+		a.keys[key.ID] = key
+	}
+
+	return a, nil
+}
+
+func (a *AccessTokens) New(user *User) (*AccessToken, error) {
+	now := time.Now()
+	expiry := now.Add(a.ttl)
+
+	header, err := json.Marshal(accessTokenHeader{Alg: a.current.Alg, Typ: "JWT", Kid: a.current.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := json.Marshal(accessTokenClaims{
+		Issuer:    accessTokenIssuer,
+		Subject:   strconv.FormatInt(user.UserID, 10),
+		Role:      user.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiry.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message := b64.EncodeToString(header) + "." + b64.EncodeToString(claims)
+	signature := a.current.sign([]byte(message))
+
+	return &AccessToken{
+		Plaintext: message + "." + b64.EncodeToString(signature),
+		Expiry:    time.Unix(expiry.Unix(), 0),
+	}, nil
+}
+
+// Revoke rejects the tokens issued to the user before now, after a role or
+// password change, a logout or deletion. Tokens are issued with a precision
+// of a second, so those from the same second as the revocation, such as the
+// one PATCH /me returns, stay valid.
+func (a *AccessTokens) Revoke(userID int64) {
+	a.RevokeBefore(userID, time.Now())
+}
+
+// RevokeBefore rejects the tokens issued to the user before t, as recorded
+// by another instance. An earlier t than one already known changes nothing.
+func (a *AccessTokens) RevokeBefore(userID int64, t time.Time) {
+	now := time.Now().Unix()
+	before := t.Unix()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if before > a.revoked[userID] {
+		a.revoked[userID] = before
+	}
+
+	// Tokens issued before a revocation older than the TTL have expired
+	// anyway.
+	for id, before := range a.revoked {
+		if now-before > int64(a.ttl/time.Second) {
+			delete(a.revoked, id)
+		}
+	}
+}
+
+func (a *AccessTokens) revokedBefore(userID int64) int64 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.revoked[userID]
+}
+
+// Verify checks the signature, expiry and revocation of an access token and
+// returns its user. Only the ID and role of the user are set.
+func (a *AccessTokens) Verify(token string) (*User, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidAccessToken
+	}
+
+	var header accessTokenHeader
+	if !decodeSegment(parts[0], &header) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	// The algorithm must be the key's own, never what the token asks for.
+	key, ok := a.keys[header.Kid]
+	if !ok || header.Alg != key.Alg {
+		return nil, ErrInvalidAccessToken
+	}
+
+	signature, err := b64.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	var claims accessTokenClaims
+	if !decodeSegment(parts[1], &claims) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if claims.Issuer != accessTokenIssuer || time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidAccessToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || userID < 1 {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if claims.Role != RoleUser && claims.Role != RoleAdmin {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if claims.IssuedAt < a.revokedBefore(userID) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	return &User{UserID: userID, Role: claims.Role}, nil
+}
+
+func decodeSegment(segment string, dst any) bool {
+	raw, err := b64.DecodeString(segment)
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(raw, dst) == nil
+}
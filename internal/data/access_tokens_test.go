@@ -0,0 +1,262 @@
+package data
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigningKey(t *testing.T, id, alg string, fill byte) SigningKey {
+	t.Helper()
+
+	key, err := ParseSigningKey(id + ":" + alg + ":" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{fill}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func testAccessTokens(t *testing.T, ttl time.Duration, keys ...SigningKey) *AccessTokens {
+	t.Helper()
+
+	a, err := NewAccessTokens(ttl, keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+func TestParseSigningKey(t *testing.T) {
+	short := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	seed := base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"a:HS256:" + seed, true},
+		{"a:EdDSA:" + seed, true},
+		{"a:HS256:" + short, false},
+		{"a:EdDSA:" + short, false},
+		{"a:RS256:" + seed, false},
+		{"a:none:" + seed, false},
+		{"a:HS256:not base64!", false},
+		{":HS256:" + seed, false},
+		{"a:HS256", false},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseSigningKey(tt.spec)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseSigningKey(%q) error %v; want valid %v", tt.spec, err, tt.valid)
+		}
+	}
+}
+
+func TestNewAccessTokensRejectsDuplicateKeys(t *testing.T) {
+	if _, err := NewAccessTokens(time.Minute); err == nil {
+		t.Error("no keys accepted")
+	}
+
+	key := testSigningKey(t, "a", AlgHS256, 'a')
+	if _, err := NewAccessTokens(time.Minute, key, key); err == nil {
+		t.Error("duplicate kid accepted")
+	}
+}
+
+func TestAccessTokensSignAndVerify(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			a := testAccessTokens(t, time.Minute, testSigningKey(t, "k1", alg, 'a'))
+
+			token, err := a.New(&User{UserID: 7, Role: RoleAdmin})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if until := time.Until(token.Expiry); until <= 0 || until > time.Minute {
+				t.Errorf("expiry %s from now; want within a minute", until)
+			}
+
+			user, err := a.Verify(token.Plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user.UserID != 7 || user.Role != RoleAdmin {
+				t.Errorf("got user %d %s; want 7 admin", user.UserID, user.Role)
+			}
+
+			var header accessTokenHeader
+			if !decodeSegment(strings.Split(token.Plaintext, ".")[0], &header) || header.Alg != alg || header.Kid != "k1" {
+				t.Errorf("got header %+v", header)
+			}
+		})
+	}
+}
+
+func TestAccessTokensExpiry(t *testing.T) {
+	a := testAccessTokens(t, -time.Second, testSigningKey(t, "k1", AlgHS256, 'a'))
+
+	token, err := a.New(&User{UserID: 1, Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Verify(token.Plaintext); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("expired token: got %v; want ErrInvalidAccessToken", err)
+	}
+}
+
+func TestAccessTokensRotation(t *testing.T) {
+	oldKey := testSigningKey(t, "old", AlgHS256, 'a')
+	newKey := testSigningKey(t, "new", AlgEdDSA, 'b')
+
+	before := testAccessTokens(t, time.Minute, oldKey)
+	oldToken, err := before.New(&User{UserID: 1, Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new key signs, the old one still verifies.
+	during := testAccessTokens(t, time.Minute, newKey, oldKey)
+
+	newToken, err := during.New(&User{UserID: 1, Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]*AccessToken{"old": oldToken, "new": newToken} {
+		if _, err := during.Verify(token.Plaintext); err != nil {
+			t.Errorf("%s token during rotation: %v", name, err)
+		}
+	}
+
+	// Once the old key is dropped, its tokens are rejected.
+	after := testAccessTokens(t, time.Minute, newKey)
+
+	if _, err := after.Verify(oldToken.Plaintext); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("token of a dropped key: got %v; want ErrInvalidAccessToken", err)
+	}
+	if _, err := after.Verify(newToken.Plaintext); err != nil {
+		t.Errorf("new token after rotation: %v", err)
+	}
+}
+
+func TestAccessTokensRejectTampering(t *testing.T) {
+	key := testSigningKey(t, "k1", AlgHS256, 'a')
+	a := testAccessTokens(t, time.Minute, key)
+
+	token, err := a.New(&User{UserID: 1, Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := strings.Split(token.Plaintext, ".")
+
+	encode := func(v any) string {
+		js, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b64.EncodeToString(js)
+	}
+
+	var claims accessTokenClaims
+	decodeSegment(parts[1], &claims)
+	claims.Role = RoleAdmin
+
+	// A token signed by a key that isn't configured, under a known kid.
+	other := testAccessTokens(t, time.Minute, testSigningKey(t, "k1", AlgHS256, 'b'))
+	forged, err := other.New(&User{UserID: 1, Role: RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"empty":           "",
+		"two segments":    parts[0] + "." + parts[1],
+		"escalated role":  parts[0] + "." + encode(claims) + "." + parts[2],
+		"no signature":    parts[0] + "." + parts[1] + ".",
+		"alg none":        encode(accessTokenHeader{Alg: "none", Typ: "JWT", Kid: "k1"}) + "." + parts[1] + ".",
+		"alg switched":    encode(accessTokenHeader{Alg: AlgEdDSA, Typ: "JWT", Kid: "k1"}) + "." + parts[1] + "." + parts[2],
+		"unknown kid":     encode(accessTokenHeader{Alg: AlgHS256, Typ: "JWT", Kid: "k2"}) + "." + parts[1] + "." + parts[2],
+		"foreign key":     forged.Plaintext,
+		"bad base64":      parts[0] + ".!!!." + parts[2],
+		"wrong signature": parts[0] + "." + parts[1] + "." + b64.EncodeToString(make([]byte, 32)),
+	}
+
+	for name, token := range tests {
+		if _, err := a.Verify(token); !errors.Is(err, ErrInvalidAccessToken) {
+			t.Errorf("%s: got %v; want ErrInvalidAccessToken", name, err)
+		}
+	}
+}
+
+func TestAccessTokensRevoke(t *testing.T) {
+	a := testAccessTokens(t, time.Minute, testSigningKey(t, "k1", AlgHS256, 'a'))
+
+	token, err := a.New(&User{UserID: 1, Role: RoleAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := a.New(&User{UserID: 2, Role: RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims accessTokenClaims
+	if !decodeSegment(strings.Split(token.Plaintext, ".")[1], &claims) {
+		t.Fatal("can't decode claims")
+	}
+
+	// A token issued in the same second as the revocation, like the one
+	// PATCH /me returns, stays valid.
+	a.revoked[1] = claims.IssuedAt
+
+	if _, err := a.Verify(token.Plaintext); err != nil {
+		t.Errorf("token from the second of the revocation: %v", err)
+	}
+
+	a.revoked[1]++
+
+	if _, err := a.Verify(token.Plaintext); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Errorf("revoked token: got %v; want ErrInvalidAccessToken", err)
+	}
+	if _, err := a.Verify(other.Plaintext); err != nil {
+		t.Errorf("another user's token: %v", err)
+	}
+}
+
+func TestAccessTokensRevokeForgetsExpired(t *testing.T) {
+	a := testAccessTokens(t, time.Minute, testSigningKey(t, "k1", AlgHS256, 'a'))
+
+	a.revoked[1] = time.Now().Add(-2 * time.Minute).Unix()
+
+	a.Revoke(2)
+
+	if _, ok := a.revoked[1]; ok {
+		t.Error("revocation older than the TTL was kept")
+	}
+	if _, ok := a.revoked[2]; !ok {
+		t.Error("new revocation is missing")
+	}
+}
+
+func TestAccessTokensRevokeBeforeKeepsTheLatest(t *testing.T) {
+	a := testAccessTokens(t, time.Minute, testSigningKey(t, "k1", AlgHS256, 'a'))
+
+	later := time.Now().Add(time.Second)
+
+	a.RevokeBefore(1, later)
+	a.RevokeBefore(1, later.Add(-time.Hour))
+
+	if a.revoked[1] != later.Unix() {
+		t.Errorf("revoked before %d, want %d", a.revoked[1], later.Unix())
+	}
+}
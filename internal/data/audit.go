@@ -0,0 +1,170 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"time"
+)
+
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditRestore = "restore"
+	AuditPurge   = "purge"
+
+	AuditTargetBanner = "banner"
+	AuditTargetUser   = "user"
+)
+
+// Actor identifies who performed a mutation. A zero UserID is recorded as an
+// anonymous or system actor.
+type Actor struct {
+	UserID    int64
+	RequestID string
+}
+
+type AuditEntry struct {
+	AuditID    int64           `json:"audit_id"`
+	ActorID    *int64          `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   int64           `json:"target_id"`
+	Diff       json.RawMessage `json:"diff"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// auditDiff keeps only the top-level fields that differ between before and
+// after. A nil side is recorded as null, so creates and hard deletes carry
+// the whole record.
+func auditDiff(before, after any) ([]byte, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if other, ok := afterFields[key]; ok && reflect.DeepEqual(value, other) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+
+	return json.Marshal(map[string]any{"before": beforeFields, "after": afterFields})
+}
+
+func auditFields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil, nil
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+
+	err = json.Unmarshal(js, &fields)
+	return fields, err
+}
+
+func insertAudit(ctx context.Context, tx *sql.Tx, actor Actor, action, targetType string, targetID int64, before, after any) error {
+	diff, err := auditDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO audit_log (actor_id, action, target_type, target_id, diff, request_id)
+        VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6)`
+
+	args := []any{actor.UserID, action, targetType, targetID, diff, actor.RequestID}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+type AuditModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+func (m AuditModel) GetAll(ctx context.Context, filters AuditFilters) ([]*AuditEntry, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), audit_id, actor_id, action, target_type, target_id, diff, request_id, created_at
+        FROM audit_log
+        WHERE (actor_id = $1 OR $1 = 0)
+            AND (target_type = $2 OR $2 = '')
+            AND (target_id = $3 OR $3 = 0)
+            AND (created_at >= $4 OR $4 IS NULL)
+            AND (created_at <= $5 OR $5 IS NULL)
+        ORDER BY created_at DESC, audit_id DESC
+        LIMIT $6 OFFSET $7`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	args := []any{
+		filters.ActorID,
+		filters.TargetType,
+		filters.TargetID,
+		sql.NullTime{Time: filters.From, Valid: !filters.From.IsZero()},
+		sql.NullTime{Time: filters.To, Valid: !filters.To.IsZero()},
+		filters.Limit,
+		filters.Offset,
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*AuditEntry{}
+
+	for rows.Next() {
+		var entry AuditEntry
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.AuditID,
+			&entry.ActorID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.Diff,
+			&entry.RequestID,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Offset, filters.Limit)
+
+	return entries, metadata, nil
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestAuditDiffUserPassword(t *testing.T) {
+	before := &User{UserID: 1, UserName: "alice", Role: RoleUser}
+	before.Password.hash = []byte("old hash")
+
+	after := *before
+	after.Password.hash = []byte("new hash")
+
+	js, err := auditDiff(userAudit{User: before}, userAudit{User: &after, PasswordChanged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]map[string]any
+
+	err = json.Unmarshal(js, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]any{
+		"before": {"password_changed": false},
+		"after":  {"password_changed": true},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diff %s, want password_changed going from false to true", js)
+	}
+}
+
+func TestAuditDiffUserUnchangedPassword(t *testing.T) {
+	before := &User{UserID: 1, UserName: "alice", Role: RoleUser}
+
+	after := *before
+	after.Role = RoleAdmin
+
+	js, err := auditDiff(userAudit{User: before}, userAudit{User: &after})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]map[string]any
+
+	err = json.Unmarshal(js, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]any{
+		"before": {"role": "user"},
+		"after":  {"role": "admin"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diff %s, want only the role", js)
+	}
+}
@@ -7,18 +7,18 @@ import (
 	"errors"
 	"time"
 
-	"github.com/lib/pq"
 	"github.com/skraio/banner-service/internal/validator"
 )
 
 type Banner struct {
-	BannerID  int64     `json:"banner_id"`
-	FeatureID int64     `json:"feature_id"`
-	TagIDs    []int64   `json:"tag_ids"`
-	Content   Content   `json:"content"`
-	IsActive  bool      `json:"is_active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	BannerID  int64      `json:"banner_id"`
+	FeatureID int64      `json:"feature_id"`
+	TagIDs    []int64    `json:"tag_ids"`
+	Content   Content    `json:"content"`
+	IsActive  bool       `json:"is_active"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type Content struct {
@@ -34,6 +34,16 @@ func (c *Content) Scan(src interface{}) error {
 	return json.Unmarshal(src.([]byte), c)
 }
 
+// CheckAccess reports ErrForbiddenAccess when a user with the given role may
+// not see the banner.
+func (banner *Banner) CheckAccess(userRole Role) error {
+	if !banner.IsActive && userRole == RoleUser {
+		return ErrForbiddenAccess
+	}
+
+	return nil
+}
+
 func ValidateBanner(v *validator.Validator, banner *Banner) {
 	v.Check(banner.BannerID >= 0, "banner_id", "must be positive")
 
@@ -55,11 +65,15 @@ func ValidateBanner(v *validator.Validator, banner *Banner) {
 	v.Check(validator.Matches(banner.Content.URL, validator.UrlRX), "content.url", "must be a valid URL")
 }
 
+// BannerModel sends Get and GetAll to Replicas when there are any. Writes,
+// last-revision reads and everything else go to DB, the primary.
 type BannerModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Replicas *ReplicaSet
+	Timeouts Timeouts
 }
 
-func (b BannerModel) Insert(banner *Banner) error {
+func (b BannerModel) Insert(ctx context.Context, banner *Banner, actor Actor) error {
 	contentJSON, err := json.Marshal(banner.Content)
 	if err != nil {
 		return err
@@ -70,15 +84,22 @@ func (b BannerModel) Insert(banner *Banner) error {
         VALUES ($1, $2, $3, $4)
         RETURNING banner_id, created_at, updated_at`
 
-	args := []interface{}{pq.Array(banner.TagIDs), banner.FeatureID, string(contentJSON), banner.IsActive}
+	args := []interface{}{banner.TagIDs, banner.FeatureID, string(contentJSON), banner.IsActive}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Write)
 	defer cancel()
 
-	return b.DB.QueryRowContext(ctx, query, args...).Scan(&banner.BannerID, &banner.CreatedAt, &banner.UpdatedAt)
+	return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&banner.BannerID, &banner.CreatedAt, &banner.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, actor, AuditCreate, AuditTargetBanner, banner.BannerID, nil, banner)
+	})
 }
 
-func (b BannerModel) Get(filters UserFilters, userRole Role) (*Banner, error) {
+func (b BannerModel) Get(ctx context.Context, filters UserFilters) (*Banner, error) {
 	if filters.TagID < 1 || filters.FeatureID < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -86,20 +107,30 @@ func (b BannerModel) Get(filters UserFilters, userRole Role) (*Banner, error) {
 	query := `
         SELECT banner_id, content, created_at, updated_at, is_active
         FROM banners
-        WHERE $1 = ANY(tag_ids) AND feature_id = $2`
+        WHERE $1 = ANY(tag_ids) AND feature_id = $2 AND deleted_at IS NULL`
 
 	var banner Banner
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Read)
 	defer cancel()
 
-	err := b.DB.QueryRowContext(ctx, query, filters.TagID, filters.FeatureID).Scan(
-		&banner.BannerID,
-		&banner.Content,
-		&banner.CreatedAt,
-		&banner.UpdatedAt,
-		&banner.IsActive,
-	)
+	scan := func(db *sql.DB) error {
+		return db.QueryRowContext(ctx, query, filters.TagID, filters.FeatureID).Scan(
+			&banner.BannerID,
+			&banner.Content,
+			&banner.CreatedAt,
+			&banner.UpdatedAt,
+			&banner.IsActive,
+		)
+	}
+
+	var err error
+
+	if filters.UseLastRevision {
+		err = scan(b.DB)
+	} else {
+		err = b.Replicas.read(ctx, b.DB, scan)
+	}
 
 	if err != nil {
 		switch {
@@ -110,14 +141,10 @@ func (b BannerModel) Get(filters UserFilters, userRole Role) (*Banner, error) {
 		}
 	}
 
-	if !banner.IsActive && userRole == RoleUser {
-		return nil, ErrForbiddenAccess
-	}
-
 	return &banner, nil
 }
 
-func (b BannerModel) GetByID(banner_id int64) (*Banner, error) {
+func (b BannerModel) GetByID(ctx context.Context, banner_id int64) (*Banner, error) {
 	if banner_id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -125,11 +152,11 @@ func (b BannerModel) GetByID(banner_id int64) (*Banner, error) {
 	query := `
         SELECT banner_id, content, created_at, updated_at, is_active, tag_ids, feature_id
         FROM banners
-        WHERE banner_id = $1`
+        WHERE banner_id = $1 AND deleted_at IS NULL`
 
 	var banner Banner
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Read)
 	defer cancel()
 
 	err := b.DB.QueryRowContext(ctx, query, banner_id).Scan(
@@ -138,7 +165,7 @@ func (b BannerModel) GetByID(banner_id int64) (*Banner, error) {
 		&banner.CreatedAt,
 		&banner.UpdatedAt,
 		&banner.IsActive,
-		pq.Array(&banner.TagIDs),
+		pgArray{&banner.TagIDs},
 		&banner.FeatureID,
 	)
 
@@ -154,20 +181,27 @@ func (b BannerModel) GetByID(banner_id int64) (*Banner, error) {
 	return &banner, nil
 }
 
-func (b BannerModel) GetAll(filters AdminFilters) ([]*Banner, Metadata, error) {
+func (b BannerModel) GetAll(ctx context.Context, filters AdminFilters) ([]*Banner, Metadata, error) {
 	query := `
         SELECT count(*) OVER(), banner_id, tag_ids, feature_id, content, is_active, created_at, updated_at
         FROM banners
         WHERE (feature_id = $1 OR $1 = 0)
             AND ($2 = ANY(tag_ids) OR $2 = 0)
+            AND deleted_at IS NULL
         LIMIT $3 OFFSET $4`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Read)
 	defer cancel()
 
 	args := []any{filters.FeatureID, filters.TagID, filters.Limit, filters.Offset}
 
-	rows, err := b.DB.QueryContext(ctx, query, args...)
+	var rows *sql.Rows
+
+	err := b.Replicas.read(ctx, b.DB, func(db *sql.DB) error {
+		var err error
+		rows, err = db.QueryContext(ctx, query, args...)
+		return err
+	})
 	if err != nil {
 		return nil, Metadata{}, err
 	}
@@ -183,7 +217,7 @@ func (b BannerModel) GetAll(filters AdminFilters) ([]*Banner, Metadata, error) {
 		err := rows.Scan(
 			&totalRecords,
 			&banner.BannerID,
-			pq.Array(&banner.TagIDs),
+			pgArray{&banner.TagIDs},
 			&banner.FeatureID,
 			&banner.Content,
 			&banner.IsActive,
@@ -206,7 +240,137 @@ func (b BannerModel) GetAll(filters AdminFilters) ([]*Banner, Metadata, error) {
 	return banners, metadata, nil
 }
 
-func (b BannerModel) Update(banner *Banner) error {
+func (b BannerModel) Export(ctx context.Context, filters AdminFilters, fn func(*Banner) error) error {
+	query := `
+        SELECT banner_id, tag_ids, feature_id, content, is_active, created_at, updated_at
+        FROM banners
+        WHERE (feature_id = $1 OR $1 = 0)
+            AND ($2 = ANY(tag_ids) OR $2 = 0)
+            AND deleted_at IS NULL
+        ORDER BY banner_id
+        LIMIT NULLIF($3, 0) OFFSET $4`
+
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Bulk)
+	defer cancel()
+
+	args := []any{filters.FeatureID, filters.TagID, filters.Limit, filters.Offset}
+
+	rows, err := b.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var banner Banner
+
+		err := rows.Scan(
+			&banner.BannerID,
+			pgArray{&banner.TagIDs},
+			&banner.FeatureID,
+			&banner.Content,
+			&banner.IsActive,
+			&banner.CreatedAt,
+			&banner.UpdatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&banner)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (b BannerModel) GetActive(ctx context.Context) ([]*Banner, error) {
+	query := `
+        SELECT banner_id, tag_ids, feature_id, content, is_active, created_at, updated_at
+        FROM banners
+        WHERE is_active AND deleted_at IS NULL`
+
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Bulk)
+	defer cancel()
+
+	rows, err := b.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	banners := []*Banner{}
+
+	for rows.Next() {
+		var banner Banner
+
+		err := rows.Scan(
+			&banner.BannerID,
+			pgArray{&banner.TagIDs},
+			&banner.FeatureID,
+			&banner.Content,
+			&banner.IsActive,
+			&banner.CreatedAt,
+			&banner.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		banners = append(banners, &banner)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return banners, nil
+}
+
+func (b BannerModel) InsertMany(ctx context.Context, banners []*Banner, actor Actor) error {
+	query := `
+        INSERT INTO banners (tag_ids, feature_id, content, is_active)
+        VALUES ($1, $2, $3, $4)
+        RETURNING banner_id, created_at, updated_at`
+
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Bulk)
+	defer cancel()
+
+	return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, banner := range banners {
+			contentJSON, err := json.Marshal(banner.Content)
+			if err != nil {
+				return err
+			}
+
+			args := []any{banner.TagIDs, banner.FeatureID, string(contentJSON), banner.IsActive}
+
+			err = stmt.QueryRowContext(ctx, args...).Scan(&banner.BannerID, &banner.CreatedAt, &banner.UpdatedAt)
+			if err != nil {
+				return err
+			}
+
+			err = insertAudit(ctx, tx, actor, AuditCreate, AuditTargetBanner, banner.BannerID, nil, banner)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (b BannerModel) Update(ctx context.Context, banner *Banner, actor Actor) error {
 	query := `
         UPDATE banners
         SET
@@ -215,7 +379,7 @@ func (b BannerModel) Update(banner *Banner) error {
             content = $3,
             is_active = $4,
             updated_at = NOW()
-        WHERE banner_id = $5 AND updated_at = $6
+        WHERE banner_id = $5 AND updated_at = $6 AND deleted_at IS NULL
         RETURNING updated_at`
 
 	contentJSON, err := json.Marshal(banner.Content)
@@ -224,7 +388,7 @@ func (b BannerModel) Update(banner *Banner) error {
 	}
 
 	args := []any{
-		pq.Array(banner.TagIDs),
+		banner.TagIDs,
 		banner.FeatureID,
 		contentJSON,
 		banner.IsActive,
@@ -232,47 +396,210 @@ func (b BannerModel) Update(banner *Banner) error {
 		banner.UpdatedAt,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Write)
 	defer cancel()
 
-	err = b.DB.QueryRowContext(ctx, query, args...).Scan(&banner.UpdatedAt)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
-		default:
+	return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+		before, err := lockBanner(ctx, tx, banner.BannerID, false)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrRecordNotFound):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, query, args...).Scan(&banner.UpdatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
+			default:
+				return err
+			}
+		}
+
+		return insertAudit(ctx, tx, actor, AuditUpdate, AuditTargetBanner, banner.BannerID, before, banner)
+	})
+}
+
+func (b BannerModel) Delete(ctx context.Context, banner_id int64, actor Actor) error {
+	if banner_id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        UPDATE banners
+        SET deleted_at = NOW()
+        WHERE banner_id = $1
+        RETURNING deleted_at`
+
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Write)
+	defer cancel()
+
+	return withTx(ctx, b.DB, func(tx *sql.Tx) error {
+		before, err := lockBanner(ctx, tx, banner_id, false)
+		if err != nil {
 			return err
 		}
+
+		after := *before
+
+		err = tx.QueryRowContext(ctx, query, banner_id).Scan(&after.DeletedAt)
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, actor, AuditDelete, AuditTargetBanner, banner_id, before, &after)
+	})
+}
+
+func (b BannerModel) GetTrash(ctx context.Context, filters AdminFilters) ([]*Banner, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), banner_id, tag_ids, feature_id, content, is_active, created_at, updated_at, deleted_at
+        FROM banners
+        WHERE deleted_at IS NOT NULL
+            AND (feature_id = $1 OR $1 = 0)
+            AND ($2 = ANY(tag_ids) OR $2 = 0)
+        ORDER BY deleted_at DESC, banner_id
+        LIMIT $3 OFFSET $4`
+
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Read)
+	defer cancel()
+
+	args := []any{filters.FeatureID, filters.TagID, filters.Limit, filters.Offset}
+
+	rows, err := b.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
 	}
 
-	return nil
+	defer rows.Close()
+
+	totalRecords := 0
+	banners := []*Banner{}
+
+	for rows.Next() {
+		var banner Banner
+
+		err := rows.Scan(
+			&totalRecords,
+			&banner.BannerID,
+			pgArray{&banner.TagIDs},
+			&banner.FeatureID,
+			&banner.Content,
+			&banner.IsActive,
+			&banner.CreatedAt,
+			&banner.UpdatedAt,
+			&banner.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		banners = append(banners, &banner)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Offset, filters.Limit)
+
+	return banners, metadata, nil
 }
 
-func (b BannerModel) Delete(banner_id int64) error {
+func (b BannerModel) Restore(ctx context.Context, banner_id int64, actor Actor) (*Banner, error) {
 	if banner_id < 1 {
-		return ErrRecordNotFound
+		return nil, ErrRecordNotFound
 	}
 
 	query := `
-        DELETE FROM banners
-        WHERE banner_id = $1`
+        UPDATE banners
+        SET deleted_at = NULL, updated_at = NOW()
+        WHERE banner_id = $1
+        RETURNING updated_at`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Write)
 	defer cancel()
 
-	result, err := b.DB.ExecContext(ctx, query, banner_id)
+	var banner Banner
+
+	err := withTx(ctx, b.DB, func(tx *sql.Tx) error {
+		before, err := lockBanner(ctx, tx, banner_id, true)
+		if err != nil {
+			return err
+		}
+
+		banner = *before
+		banner.DeletedAt = nil
+
+		err = tx.QueryRowContext(ctx, query, banner_id).Scan(&banner.UpdatedAt)
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, actor, AuditRestore, AuditTargetBanner, banner_id, before, &banner)
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return &banner, nil
+}
+
+func (b BannerModel) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+        WITH purged AS (
+            DELETE FROM banners
+            WHERE deleted_at < NOW() - make_interval(secs => $1)
+            RETURNING *
+        )
+        INSERT INTO audit_log (action, target_type, target_id, diff)
+        SELECT $2, $3, purged.banner_id, jsonb_build_object('before', to_jsonb(purged), 'after', NULL)
+        FROM purged`
+
+	ctx, cancel := withTimeout(ctx, b.Timeouts.Bulk)
+	defer cancel()
+
+	result, err := b.DB.ExecContext(ctx, query, retention.Seconds(), AuditPurge, AuditTargetBanner)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	return result.RowsAffected()
+}
+
+// lockBanner reads a banner inside tx and holds a row lock on it until the
+// transaction ends. deleted selects whether the banner must be in the trash.
+func lockBanner(ctx context.Context, tx *sql.Tx, banner_id int64, deleted bool) (*Banner, error) {
+	query := `
+        SELECT banner_id, tag_ids, feature_id, content, is_active, created_at, updated_at, deleted_at
+        FROM banners
+        WHERE banner_id = $1 AND (deleted_at IS NOT NULL) = $2
+        FOR UPDATE`
+
+	var banner Banner
+
+	err := tx.QueryRowContext(ctx, query, banner_id, deleted).Scan(
+		&banner.BannerID,
+		pgArray{&banner.TagIDs},
+		&banner.FeatureID,
+		&banner.Content,
+		&banner.IsActive,
+		&banner.CreatedAt,
+		&banner.UpdatedAt,
+		&banner.DeletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
 
-	return nil
+	return &banner, nil
 }
@@ -0,0 +1,84 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strings"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/skraio/banner-service/internal/testdb"
+)
+
+// BenchmarkBannerModelGet compares the repeated user banner query through
+// pgx, which caches prepared statements per connection, with lib/pq, which
+// the service used before. Both read the same migrated schema:
+//
+//	BANNER_TEST_DSN=postgres://... go test -run '^$' -bench BannerModelGet -benchmem ./internal/data
+func BenchmarkBannerModelGet(b *testing.B) {
+	pgxDB := testdb.Open(b)
+	ctx := context.Background()
+
+	err := NewModels(pgxDB, nil, DefaultTimeouts).Banners.Insert(ctx, &Banner{
+		FeatureID: 1,
+		TagIDs:    []int64{1, 2, 3},
+		Content:   Content{Title: "title", Text: "text", URL: "https://example.com"},
+		IsActive:  true,
+	}, Actor{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var schema string
+	if err := pgxDB.QueryRowContext(ctx, "SELECT current_schema()").Scan(&schema); err != nil {
+		b.Fatal(err)
+	}
+
+	pqDB, err := sql.Open("postgres", withSearchPath(testdb.DSN(b), schema+",public"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { pqDB.Close() })
+
+	filters := UserFilters{TagID: 2, FeatureID: 1, UseLastRevision: true}
+
+	for _, driver := range []struct {
+		name string
+		db   *sql.DB
+	}{
+		{"pgx", pgxDB},
+		{"pq", pqDB},
+	} {
+		b.Run(driver.name, func(b *testing.B) {
+			banners := NewModels(driver.db, nil, DefaultTimeouts).Banners
+
+			// Warm up the pool so that connecting isn't measured.
+			if _, err := banners.Get(ctx, filters); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := banners.Get(ctx, filters); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// withSearchPath adds a search_path run-time parameter to a URL or
+// key=value DSN.
+func withSearchPath(dsn, searchPath string) string {
+	if u, err := url.Parse(dsn); err == nil && strings.HasPrefix(u.Scheme, "postgres") {
+		q := u.Query()
+		q.Set("search_path", searchPath)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	return dsn + " search_path='" + searchPath + "'"
+}
@@ -0,0 +1,178 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUnavailable is returned instead of connecting while the breaker is open.
+var ErrUnavailable = errors.New("database unavailable")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// Breaker stops new connections to a database that keeps failing to accept
+// them. After threshold failed connection attempts in a row it opens and
+// fails every attempt with ErrUnavailable for cooldown, then lets a single
+// attempt through to probe the database. A successful probe closes it.
+// onChange, when set, is called after the breaker opens or closes.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(state BreakerState)
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	trips    int64
+}
+
+func NewBreaker(threshold int, cooldown time.Duration, onChange func(state BreakerState)) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		onChange:  onChange,
+		state:     BreakerClosed,
+	}
+}
+
+// State returns the current state. An open breaker whose cooldown has passed
+// reports half-open even before the probe starts.
+func (b *Breaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
+
+// Open reports whether requests are failing fast.
+func (b *Breaker) Open() bool {
+	return b.State() == BreakerOpen
+}
+
+// RetryAfter returns the time left until the next probe.
+func (b *Breaker) RetryAfter() time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerOpen {
+		return 0
+	}
+
+	return max(b.cooldown-time.Since(b.openedAt), 0)
+}
+
+// Trips returns how many times the breaker has opened.
+func (b *Breaker) Trips() int64 {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.trips
+}
+
+// Connector wraps c so that every new connection goes through the breaker.
+func (b *Breaker) Connector(c driver.Connector) driver.Connector {
+	return breakerConnector{Connector: c, breaker: b}
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// Only the probe gets through.
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+
+	from := b.state
+
+	if err == nil {
+		b.state = BreakerClosed
+		b.failures = 0
+	} else {
+		b.failures++
+
+		if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+			if b.state == BreakerClosed {
+				b.trips++
+			}
+			b.state = BreakerOpen
+			b.openedAt = time.Now()
+		}
+	}
+
+	to := b.state
+
+	b.mu.Unlock()
+
+	// A failed probe reopens a half-open breaker, which isn't news.
+	if b.onChange != nil && to != from && !(from == BreakerHalfOpen && to == BreakerOpen) {
+		b.onChange(to)
+	}
+}
+
+type breakerConnector struct {
+	driver.Connector
+	breaker *Breaker
+}
+
+func (c breakerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if !c.breaker.allow() {
+		return nil, ErrUnavailable
+	}
+
+	conn, err := c.Connector.Connect(ctx)
+
+	// A caller that gave up says nothing about the database, but a probe
+	// must still settle the half-open state. A deadline is different: a
+	// database that doesn't answer in time is exactly what the breaker is
+	// for, so timeouts count as failures.
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		if c.breaker.State() == BreakerHalfOpen {
+			c.breaker.record(err)
+		}
+		return nil, err
+	}
+
+	c.breaker.record(err)
+
+	return conn, err
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubConnector fails every Connect with err, or with the context's error
+// once the context is done.
+type stubConnector struct {
+	err error
+}
+
+func (c stubConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return nil, c.err
+}
+
+func (c stubConnector) Driver() driver.Driver { return nil }
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	var changes []BreakerState
+	b := NewBreaker(2, time.Hour, func(state BreakerState) { changes = append(changes, state) })
+	c := b.Connector(stubConnector{err: errors.New("connection refused")})
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Connect(context.Background()); errors.Is(err, ErrUnavailable) {
+			t.Fatalf("attempt %d: breaker opened early", i+1)
+		}
+	}
+
+	if _, err := c.Connect(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("got %v; want ErrUnavailable", err)
+	}
+
+	if b.State() != BreakerOpen || b.Trips() != 1 {
+		t.Errorf("state %s, trips %d; want open, 1", b.State(), b.Trips())
+	}
+	if len(changes) != 1 || changes[0] != BreakerOpen {
+		t.Errorf("onChange got %v; want [open]", changes)
+	}
+}
+
+func TestBreakerIgnoresCanceledConnects(t *testing.T) {
+	b := NewBreaker(1, time.Hour, nil)
+	c := b.Connector(stubConnector{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Connect(ctx); !errors.Is(err, context.Canceled) {
+			t.Fatalf("got %v; want context.Canceled", err)
+		}
+	}
+
+	if b.State() != BreakerClosed {
+		t.Errorf("state %s; want closed", b.State())
+	}
+}
+
+func TestBreakerCountsTimedOutConnects(t *testing.T) {
+	b := NewBreaker(1, time.Hour, nil)
+	c := b.Connector(stubConnector{})
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+	defer cancel()
+
+	if _, err := c.Connect(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v; want context.DeadlineExceeded", err)
+	}
+
+	if b.State() != BreakerOpen {
+		t.Errorf("state %s; want open", b.State())
+	}
+}
+
+func TestBreakerProbe(t *testing.T) {
+	b := NewBreaker(1, time.Millisecond, nil)
+	failing := b.Connector(stubConnector{err: errors.New("connection refused")})
+
+	failing.Connect(context.Background())
+	time.Sleep(2 * time.Millisecond)
+
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("state %s; want half-open", b.State())
+	}
+
+	// A canceled probe still settles the half-open state.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failing.Connect(ctx)
+
+	if b.State() != BreakerOpen {
+		t.Fatalf("state %s after a canceled probe; want open", b.State())
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	if !b.allow() {
+		t.Fatal("probe not allowed")
+	}
+	if b.allow() {
+		t.Fatal("second attempt allowed during the probe")
+	}
+
+	b.record(nil)
+
+	if b.State() != BreakerClosed {
+		t.Errorf("state %s after a successful probe; want closed", b.State())
+	}
+}
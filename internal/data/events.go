@@ -0,0 +1,200 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// BannerEventsChannel is the Postgres NOTIFY channel that carries the id of
+// every new banner_events row.
+const BannerEventsChannel = "banner_events"
+
+// BannerCacheChannel is the Postgres NOTIFY channel that carries the feature
+// and tag pairs touched by a banner change, or "flush" when they don't fit.
+const BannerCacheChannel = "banner_cache"
+
+// BannerCacheInvalidation is one feature and the tags of a changed banner, as
+// sent on BannerCacheChannel.
+type BannerCacheInvalidation struct {
+	FeatureID int64   `json:"feature_id"`
+	TagIDs    []int64 `json:"tag_ids"`
+}
+
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+type BannerEvent struct {
+	EventID  int64           `json:"event_id"`
+	Type     string          `json:"type"`
+	BannerID int64           `json:"banner_id"`
+	Banner   json.RawMessage `json:"banner"`
+	// PreviousFeatureID is the banner's feature before an update moved it
+	// to another one.
+	PreviousFeatureID *int64        `json:"previous_feature_id,omitempty"`
+	CreatedAt         time.Time     `json:"created_at"`
+	Position          EventPosition `json:"-"`
+}
+
+// EventPosition orders events by the transaction that logged them, then by
+// id. Event ids are taken before commit, so a later id can commit first, but
+// no event can ever appear before a position that was already read.
+type EventPosition struct {
+	TxID    int64
+	EventID int64
+}
+
+// After reports whether p comes after q.
+func (p EventPosition) After(q EventPosition) bool {
+	return p.TxID > q.TxID || (p.TxID == q.TxID && p.EventID > q.EventID)
+}
+
+// committedHorizon limits reads to the transactions older than every one
+// still running, so that none of them can still log an event before the
+// position read.
+const committedHorizon = `tx_id < pg_snapshot_xmin(pg_current_snapshot())`
+
+type BannerEventModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+// Latest returns the position of the last event that can be read.
+func (m BannerEventModel) Latest(ctx context.Context) (EventPosition, error) {
+	query := `
+        SELECT tx_id::text::bigint, event_id
+        FROM banner_events
+        WHERE ` + committedHorizon + `
+        ORDER BY tx_id DESC, event_id DESC
+        LIMIT 1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	var pos EventPosition
+
+	err := m.DB.QueryRowContext(ctx, query).Scan(&pos.TxID, &pos.EventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EventPosition{}, nil
+	}
+
+	return pos, err
+}
+
+// PositionOf returns the position of the event with eventID, where a client
+// that received it resumes. When the event was purged, it is the position
+// before the oldest remaining event with a greater id, or before every event
+// not yet readable when there is none.
+func (m BannerEventModel) PositionOf(ctx context.Context, eventID int64) (EventPosition, error) {
+	query := `
+        SELECT tx_id, event_id
+        FROM (
+            SELECT 0 AS fallback, tx_id::text::bigint AS tx_id, event_id
+            FROM banner_events
+            WHERE event_id = $1
+            UNION ALL
+            SELECT 1, COALESCE(MIN(tx_id::text::bigint), pg_snapshot_xmin(pg_current_snapshot())::text::bigint), 0
+            FROM banner_events
+            WHERE event_id > $1
+        ) AS positions
+        ORDER BY fallback
+        LIMIT 1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	var pos EventPosition
+
+	err := m.DB.QueryRowContext(ctx, query, eventID).Scan(&pos.TxID, &pos.EventID)
+	return pos, err
+}
+
+// GetSince returns the events after pos in position order. Events of
+// transactions that may still be followed by an earlier position are held
+// back until those finish; pending reports whether there are any.
+//
+// The horizon is cluster-wide, so any long transaction, even an idle one
+// from another application, holds every later event back. Events older than
+// maxHold are therefore returned anyway. An event that a banner transaction
+// open for longer than maxHold logs before them is then behind the position
+// read and is skipped; it stays in the log.
+func (m BannerEventModel) GetSince(ctx context.Context, pos EventPosition, limit int, maxHold time.Duration) (events []*BannerEvent, pending bool, err error) {
+	query := `
+        SELECT event_id, event_type, banner_id, payload, previous_feature_id, created_at, tx_id::text::bigint,
+            NOT (` + committedHorizon + `) AND created_at > NOW() - make_interval(secs => $4)
+        FROM banner_events
+        WHERE (tx_id, event_id) > ($1::text::xid8, $2)
+        ORDER BY tx_id, event_id
+        LIMIT $3`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, strconv.FormatInt(pos.TxID, 10), pos.EventID, limit, maxHold.Seconds())
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer rows.Close()
+
+	events = []*BannerEvent{}
+
+	for rows.Next() {
+		var (
+			event BannerEvent
+			held  bool
+		)
+
+		err := rows.Scan(
+			&event.EventID,
+			&event.Type,
+			&event.BannerID,
+			&event.Banner,
+			&event.PreviousFeatureID,
+			&event.CreatedAt,
+			&event.Position.TxID,
+			&held,
+		)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Everything from here on is at or past a running transaction.
+		if held {
+			pending = true
+			break
+		}
+
+		event.Position.EventID = event.EventID
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	return events, pending, nil
+}
+
+func (m BannerEventModel) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+        DELETE FROM banner_events
+        WHERE created_at < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Bulk)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/testdb"
+)
+
+// TestGetSinceBoundsTheHold keeps an unrelated transaction open, as an idle
+// session of another application would. Events wait behind it for maxHold
+// at most.
+func TestGetSinceBoundsTheHold(t *testing.T) {
+	db := testdb.Open(t)
+	models := NewModels(db, nil, DefaultTimeouts)
+	ctx := context.Background()
+
+	idle, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Rollback()
+
+	if _, err := idle.ExecContext(ctx, "SELECT pg_current_xact_id()"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Banners.Insert(ctx, &Banner{FeatureID: 1, TagIDs: []int64{1}, IsActive: true}, Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, pending, err := models.Events.GetSince(ctx, EventPosition{}, 10, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 || !pending {
+		t.Fatalf("got %d events, pending %v; want the event held back", len(events), pending)
+	}
+
+	const maxHold = 200 * time.Millisecond
+
+	time.Sleep(2 * maxHold)
+
+	events, pending, err = models.Events.GetSince(ctx, EventPosition{}, 10, maxHold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || pending {
+		t.Errorf("got %d events, pending %v; want the event after maxHold", len(events), pending)
+	}
+}
@@ -1,6 +1,8 @@
 package data
 
 import (
+	"time"
+
 	"github.com/skraio/banner-service/internal/validator"
 )
 
@@ -39,10 +41,64 @@ func ValidateNonRequiredFilters(v *validator.Validator, f AdminFilters) {
 	v.Check(f.Offset >= 0, "offset", "must be non-negative")
 }
 
+func ValidateExportFilters(v *validator.Validator, f AdminFilters) {
+	if f.TagID != 0 {
+		v.Check(f.TagID > 0, "tag_id", "must be greater than zero")
+	}
+	if f.FeatureID != 0 {
+		v.Check(f.FeatureID > 0, "feature_id", "must be greater than zero")
+	}
+	v.Check(f.Limit >= 0, "limit", "must be non-negative")
+	v.Check(f.Offset >= 0, "offset", "must be non-negative")
+}
+
+type AuditFilters struct {
+	ActorID    int
+	TargetType string
+	TargetID   int
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+func ValidateAuditFilters(v *validator.Validator, f AuditFilters) {
+	if f.ActorID != 0 {
+		v.Check(f.ActorID > 0, "actor_id", "must be greater than zero")
+	}
+	if f.TargetID != 0 {
+		v.Check(f.TargetID > 0, "target_id", "must be greater than zero")
+	}
+	if f.TargetType != "" {
+		v.Check(f.TargetType == AuditTargetBanner || f.TargetType == AuditTargetUser, "target_type", "must be either banner or user")
+	}
+	if !f.From.IsZero() && !f.To.IsZero() {
+		v.Check(!f.To.Before(f.From), "to", "must not be before from")
+	}
+	v.Check(f.Limit > 0, "limit", "must be greater than zero")
+	v.Check(f.Limit <= 100, "limit", "must be a maximum of 100")
+	v.Check(f.Offset >= 0, "offset", "must be non-negative")
+}
+
+type UserListFilters struct {
+	Role   string
+	Limit  int
+	Offset int
+}
+
+func ValidateUserListFilters(v *validator.Validator, f UserListFilters) {
+	if f.Role != "" {
+		v.Check(Role(f.Role) == RoleUser || Role(f.Role) == RoleAdmin, "role", "must be either user or admin")
+	}
+	v.Check(f.Limit > 0, "limit", "must be greater than zero")
+	v.Check(f.Limit <= 100, "limit", "must be a maximum of 100")
+	v.Check(f.Offset >= 0, "offset", "must be non-negative")
+}
+
 type Metadata struct {
-	Offset       int `'json:"offset,omitempty"`
-	Limit        int `'json:"limit,omitempty"`
-	TotalRecords int `'json:"total_records,omitempty"`
+	Offset       int `json:"offset"`
+	Limit        int `json:"limit,omitempty"`
+	TotalRecords int `json:"total_records"`
 }
 
 func calculateMetadata(totalRecords, offset, limit int) Metadata {
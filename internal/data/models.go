@@ -1,8 +1,14 @@
 package data
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 var (
@@ -12,15 +18,87 @@ var (
 )
 
 type Models struct {
-	Banners BannerModel
-	Users   UserModel
-	Tokens  TokenModel
+	Banners  BannerModel
+	Users    UserModel
+	Tokens   TokenModel
+	Audit    AuditModel
+	Events   BannerEventModel
+	Webhooks WebhookModel
+}
+
+// Timeouts bound each kind of query on top of the caller's context. Read
+// covers single rows and pages, Write single-record mutations and Bulk
+// exports, imports, snapshots and purges. A zero timeout leaves the query
+// bounded by the caller's context alone.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+	Bulk  time.Duration
 }
 
-func NewModels(db *sql.DB) Models {
+// DefaultTimeouts are the timeouts the models used before they were made
+// configurable.
+var DefaultTimeouts = Timeouts{
+	Read:  time.Second,
+	Write: time.Second,
+	Bulk:  30 * time.Second,
+}
+
+// NewModels wires every model to the primary db. replicas may be nil.
+func NewModels(db *sql.DB, replicas *ReplicaSet, timeouts Timeouts) Models {
 	return Models{
-		Banners: BannerModel{DB: db},
-		Users:   UserModel{DB: db},
-		Tokens:  TokenModel{DB: db},
+		Banners:  BannerModel{DB: db, Replicas: replicas, Timeouts: timeouts},
+		Users:    UserModel{DB: db, Timeouts: timeouts},
+		Tokens:   TokenModel{DB: db, Timeouts: timeouts},
+		Audit:    AuditModel{DB: db, Timeouts: timeouts},
+		Events:   BannerEventModel{DB: db, Timeouts: timeouts},
+		Webhooks: WebhookModel{DB: db, Timeouts: timeouts},
+	}
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func withTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// isUniqueViolation reports whether err is a violation of the named unique
+// constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
+// typeMaps holds pgtype maps for pgArray. A map caches scan plans and isn't
+// safe for concurrent use.
+var typeMaps = sync.Pool{New: func() any { return pgtype.NewMap() }}
+
+// pgArray scans an array column, such as integer[], into dst, a pointer to a
+// slice. Through database/sql pgx hands arrays over in their text form, which
+// pgtype decodes. Slices are passed to queries as they are.
+type pgArray struct {
+	dst any
+}
+
+func (a pgArray) Scan(src any) error {
+	m := typeMaps.Get().(*pgtype.Map)
+	defer typeMaps.Put(m)
+
+	return m.SQLScanner(a.dst).Scan(src)
 }
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPgArrayScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     any
+		want    []int64
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "text", src: "{1,2,3}", want: []int64{1, 2, 3}},
+		{name: "bytes", src: []byte("{42}"), want: []int64{42}},
+		{name: "big", src: "{9223372036854775807}", want: []int64{9223372036854775807}},
+		{name: "empty", src: "{}", want: []int64{}},
+		{name: "null", src: nil, wantNil: true},
+		{name: "malformed", src: "1,2", wantErr: true},
+		{name: "not a number", src: "{a}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []int64{7}
+
+			err := pgArray{&got}.Scan(tt.src)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Scan(%v) = %v, want an error", tt.src, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("Scan(nil) = %v, want nil", got)
+				}
+				return
+			}
+
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("Scan(%v) = %#v, want %#v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package data
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+const (
+	// DefaultPasswordMinLength is the shortest password a default policy
+	// accepts.
+	DefaultPasswordMinLength = 8
+
+	// MaxPasswordLength is the longest password bcrypt can hash.
+	MaxPasswordLength = 72
+)
+
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// PasswordPolicy decides which new passwords are accepted. Existing
+// passwords are never checked against it, so tightening the policy doesn't
+// lock anyone out.
+type PasswordPolicy struct {
+	MinLength int
+	denylist  map[string]struct{}
+}
+
+// NewPasswordPolicy returns a policy that rejects passwords shorter than
+// minLength and the common passwords built into the service.
+func NewPasswordPolicy(minLength int) PasswordPolicy {
+	p := PasswordPolicy{
+		MinLength: minLength,
+		denylist:  make(map[string]struct{}),
+	}
+
+	// The embedded list always parses.
+	_ = p.AddDenylist(strings.NewReader(commonPasswords))
+
+	return p
+}
+
+// AddDenylist adds the passwords in r, one per line, to the denylist. Blank
+// lines and lines starting with # are skipped.
+func (p PasswordPolicy) AddDenylist(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.denylist[strings.ToLower(line)] = struct{}{}
+	}
+
+	return scanner.Err()
+}
+
+// Validate checks a new password against the policy.
+func (p PasswordPolicy) Validate(v *validator.Validator, password string) {
+	ValidatePasswordPlaintext(v, password)
+
+	v.Check(len(password) >= p.MinLength, "password", fmt.Sprintf("must be at least %d bytes long", p.MinLength))
+
+	_, common := p.denylist[strings.ToLower(password)]
+	v.Check(!common, "password", "is too common")
+}
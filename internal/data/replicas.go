@@ -0,0 +1,158 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Replica is a read replica of the primary database. Name identifies it in
+// health reports and must not contain credentials.
+type Replica struct {
+	Name string
+	DB   *sql.DB
+}
+
+type replica struct {
+	Replica
+	healthy atomic.Bool
+}
+
+// ReplicaHealth reports a replica whose health changed.
+type ReplicaHealth struct {
+	Name    string
+	Healthy bool
+	Err     error
+}
+
+// ReplicaSet routes reads to healthy replicas in turn and to the primary
+// when none is healthy. A nil *ReplicaSet routes every read to the primary.
+type ReplicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+}
+
+func NewReplicaSet(primary *sql.DB, replicas ...Replica) *ReplicaSet {
+	rs := &ReplicaSet{primary: primary}
+
+	for _, r := range replicas {
+		rep := &replica{Replica: r}
+		rep.healthy.Store(true)
+		rs.replicas = append(rs.replicas, rep)
+	}
+
+	return rs
+}
+
+func (rs *ReplicaSet) Len() int {
+	if rs == nil {
+		return 0
+	}
+	return len(rs.replicas)
+}
+
+// CheckHealth pings every replica and returns those whose health changed.
+func (rs *ReplicaSet) CheckHealth(ctx context.Context, timeout time.Duration) []ReplicaHealth {
+	if rs == nil {
+		return nil
+	}
+
+	var changed []ReplicaHealth
+
+	for _, r := range rs.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := r.DB.PingContext(pingCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return changed
+		}
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			changed = append(changed, ReplicaHealth{Name: r.Name, Healthy: healthy, Err: err})
+		}
+	}
+
+	return changed
+}
+
+func (rs *ReplicaSet) Close() error {
+	if rs == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, r := range rs.replicas {
+		errs = append(errs, r.DB.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// reader returns the next healthy replica, or nil when reads must go to the
+// primary.
+func (rs *ReplicaSet) reader() *replica {
+	n := rs.Len()
+	if n == 0 {
+		return nil
+	}
+
+	start := rs.next.Add(1)
+
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(start+uint64(i))%uint64(n)]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// read runs fn against a replica, or the primary when there is none. A
+// replica that fails to answer is marked down and fn is retried on the
+// primary, which the next health check can undo.
+func (rs *ReplicaSet) read(ctx context.Context, primary *sql.DB, fn func(db *sql.DB) error) error {
+	r := rs.reader()
+	if r == nil {
+		return fn(primary)
+	}
+
+	err := fn(r.DB)
+	if err == nil || !isReplicaFailure(ctx, err) {
+		return err
+	}
+
+	r.healthy.Store(false)
+
+	return fn(primary)
+}
+
+// isReplicaFailure tells a replica that is down or unreachable from a query
+// that failed on its own merits.
+func isReplicaFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Connection exceptions and shutdowns; 57014 is a canceled query.
+		switch pgErr.Code[:2] {
+		case "08":
+			return true
+		case "57":
+			return pgErr.Code != "57014"
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -11,6 +11,10 @@ import (
 	"github.com/skraio/banner-service/internal/validator"
 )
 
+// TokenRevocationsChannel is the Postgres NOTIFY channel that carries
+// "user_id unix_time" whenever a user's tokens are revoked.
+const TokenRevocationsChannel = "token_revocations"
+
 type Token struct {
 	Plaintext string `json:"token"`
 	Hash      []byte `json:"-"`
@@ -43,39 +47,120 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 }
 
 type TokenModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
-func (m TokenModel) New(userID int64) (*Token, error) {
+func (m TokenModel) New(ctx context.Context, userID int64) (*Token, error) {
 	token, err := generateToken(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	err = m.Insert(token)
+	err = m.Insert(ctx, token)
 	return token, err
 }
 
-func (m TokenModel) Insert(token *Token) error {
+func (m TokenModel) Insert(ctx context.Context, token *Token) error {
 	query := `
         INSERT INTO tokens (token_hash, user_id)
         VALUES ($1, $2)`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
 	defer cancel()
 
 	_, err := m.DB.ExecContext(ctx, query, token.Hash, token.UserID)
 	return err
 }
 
-func (m TokenModel) DeleteAll(userID int64) error {
+// DeleteAll deletes the user's tokens and records the revocation for signed
+// access tokens.
+func (m TokenModel) DeleteAll(ctx context.Context, userID int64) error {
 	query := `
         DELETE FROM tokens
         WHERE user_id = $1`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
 	defer cancel()
 
-	_, err := m.DB.ExecContext(ctx, query, userID)
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		return revokeTokens(ctx, tx, userID)
+	})
+}
+
+// Revocations returns, per user, the time before which their tokens were
+// revoked, for revocations since the given time.
+func (m TokenModel) Revocations(ctx context.Context, since time.Time) (map[int64]time.Time, error) {
+	query := `
+        SELECT user_id, revoked_before
+        FROM token_revocations
+        WHERE revoked_before >= $1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revocations := make(map[int64]time.Time)
+
+	for rows.Next() {
+		var (
+			userID int64
+			before time.Time
+		)
+
+		err := rows.Scan(&userID, &before)
+		if err != nil {
+			return nil, err
+		}
+
+		revocations[userID] = before
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
+// PurgeRevocations deletes revocations older than the given time, which no
+// unexpired token predates.
+func (m TokenModel) PurgeRevocations(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+        DELETE FROM token_revocations
+        WHERE revoked_before < $1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Bulk)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// revokeTokens records inside tx that the user's tokens issued before the
+// current second are revoked. The row's trigger tells every instance.
+func revokeTokens(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+        INSERT INTO token_revocations (user_id, revoked_before)
+        VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE
+        SET revoked_before = greatest(token_revocations.revoked_before, excluded.revoked_before)`
+
+	// Access tokens carry their issue time in whole seconds.
+	_, err := tx.ExecContext(ctx, query, userID, time.Unix(time.Now().Unix(), 0))
 	return err
 }
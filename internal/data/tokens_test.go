@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/testdb"
+)
+
+func TestTokenRevocations(t *testing.T) {
+	models := NewModels(testdb.Open(t), nil, DefaultTimeouts)
+	ctx := context.Background()
+
+	insertUser := func(name string) *User {
+		t.Helper()
+
+		user := &User{UserName: name, Role: RoleUser}
+		user.Password.hash = []byte("not a real hash")
+
+		err := models.Users.Insert(ctx, user, Actor{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	start := time.Now().Truncate(time.Second)
+
+	loggedOut := insertUser("logged_out")
+	promoted := insertUser("promoted")
+	deleted := insertUser("deleted")
+	untouched := insertUser("untouched")
+
+	if err := models.Tokens.DeleteAll(ctx, loggedOut.UserID); err != nil {
+		t.Fatal(err)
+	}
+
+	promoted.Role = RoleAdmin
+	if err := models.Users.Update(ctx, promoted, Actor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := models.Users.Delete(ctx, deleted.UserID, Actor{}); err != nil {
+		t.Fatal(err)
+	}
+
+	revocations, err := models.Tokens.Revocations(ctx, start.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []*User{loggedOut, promoted, deleted} {
+		before, ok := revocations[user.UserID]
+		if !ok {
+			t.Errorf("%s: no revocation", user.UserName)
+			continue
+		}
+		if before.Before(start) || before.After(time.Now()) {
+			t.Errorf("%s: revoked before %s, want between %s and now", user.UserName, before, start)
+		}
+	}
+
+	if _, ok := revocations[untouched.UserID]; ok {
+		t.Error("untouched user has a revocation")
+	}
+
+	purged, err := models.Tokens.PurgeRevocations(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 3 {
+		t.Errorf("purged %d revocations, want 3", purged)
+	}
+}
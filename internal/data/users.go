@@ -1,10 +1,12 @@
 package data
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/skraio/banner-service/internal/validator"
@@ -13,6 +15,7 @@ import (
 
 var (
 	ErrDuplicateUserName = errors.New("duplicate username")
+	ErrLastAdmin         = errors.New("last admin")
 )
 
 type Role string
@@ -32,6 +35,14 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// userAudit is the audited form of a user. The password hash is never
+// recorded, so a password change shows up as password_changed going from
+// false to true.
+type userAudit struct {
+	*User
+	PasswordChanged bool `json:"password_changed"`
+}
+
 func (u *User) IsAnonymous() bool {
 	return u == AnonymousUser
 }
@@ -67,10 +78,11 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+// ValidatePasswordPlaintext checks that a password can be hashed at all. New
+// passwords must also pass a PasswordPolicy.
 func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 	v.Check(password != "", "password", "must be provided")
-	v.Check(len(password) >= 8, "password", "must be at least 8 bytes long")
-	v.Check(len(password) <= 32, "password", "must not be more than 32 bytes long")
+	v.Check(len(password) <= MaxPasswordLength, "password", fmt.Sprintf("must not be more than %d bytes long", MaxPasswordLength))
 }
 
 func ValidateUsername(v *validator.Validator, username string) {
@@ -79,13 +91,13 @@ func ValidateUsername(v *validator.Validator, username string) {
 	v.Check(len(username) <= 20, "username", "must not be more than 20 bytes long")
 }
 
-func ValidateUserCredentials(v *validator.Validator, user *User) {
+func ValidateUserCredentials(v *validator.Validator, user *User, policy PasswordPolicy) {
 	ValidateUsername(v, user.UserName)
 
 	v.Check(user.Role == RoleUser || user.Role == RoleAdmin, "role", "must be either user or admin")
 
 	if user.Password.plaintext != nil {
-		ValidatePasswordPlaintext(v, *user.Password.plaintext)
+		policy.Validate(v, *user.Password.plaintext)
 	}
 
 	if user.Password.hash == nil {
@@ -94,24 +106,32 @@ func ValidateUserCredentials(v *validator.Validator, user *User) {
 }
 
 type UserModel struct {
-	DB *sql.DB
+	DB       *sql.DB
+	Timeouts Timeouts
 }
 
-func (m UserModel) Insert(user *User) error {
+func (m UserModel) Insert(ctx context.Context, user *User, actor Actor) error {
 	query := `
         INSERT INTO users (username, role, password_hash)
         VALUES ($1, $2, $3)
         RETURNING user_id, created_at`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
 	defer cancel()
 
 	args := []any{user.UserName, user.Role, user.Password.hash}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.UserID, &user.CreatedAt)
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&user.UserID, &user.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, actor, AuditCreate, AuditTargetUser, user.UserID, nil, user)
+	})
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		case isUniqueViolation(err, "users_username_key"):
 			return ErrDuplicateUserName
 		default:
 			return err
@@ -121,7 +141,7 @@ func (m UserModel) Insert(user *User) error {
 	return nil
 }
 
-func (m UserModel) GetByUserName(userName string) (*User, error) {
+func (m UserModel) GetByUserName(ctx context.Context, userName string) (*User, error) {
 	query := `
         SELECT user_id, username, role, password_hash, created_at
         FROM users
@@ -129,7 +149,7 @@ func (m UserModel) GetByUserName(userName string) (*User, error) {
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, userName).Scan(
@@ -151,23 +171,138 @@ func (m UserModel) GetByUserName(userName string) (*User, error) {
 	return &user, nil
 }
 
-func (m UserModel) Update(user *User) error {
+func (m UserModel) Get(ctx context.Context, userID int64) (*User, error) {
+	query := `
+        SELECT user_id, username, role, password_hash, created_at
+        FROM users
+        WHERE user_id = $1`
+
+	var user User
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&user.UserID,
+		&user.UserName,
+		&user.Role,
+		&user.Password.hash,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (m UserModel) GetAll(ctx context.Context, filters UserListFilters) ([]*User, Metadata, error) {
+	query := `
+        SELECT count(*) OVER(), user_id, username, role, password_hash, created_at
+        FROM users
+        WHERE (role::text = $1 OR $1 = '')
+        ORDER BY user_id
+        LIMIT $2 OFFSET $3`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.Role, filters.Limit, filters.Offset)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&totalRecords,
+			&user.UserID,
+			&user.UserName,
+			&user.Role,
+			&user.Password.hash,
+			&user.CreatedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Offset, filters.Limit)
+
+	return users, metadata, nil
+}
+
+// Update saves the user. A changed password or role revokes all of the
+// user's tokens, signed access tokens included, in the same transaction, and
+// the last admin can't be demoted.
+func (m UserModel) Update(ctx context.Context, user *User, actor Actor) error {
 	query := `
         UPDATE users
         SET username = $1, role = $2, password_hash = $3
-        WHERE user_id = $3`
+        WHERE user_id = $4`
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
 	defer cancel()
 
 	args := []any{user.UserName, user.Role, user.Password.hash, user.UserID}
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan()
+	err := withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		before, err := lockUser(ctx, tx, user.UserID)
+		if err != nil {
+			return err
+		}
+
+		if before.Role == RoleAdmin && user.Role != RoleAdmin {
+			err = checkOtherAdmins(ctx, tx, user.UserID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		passwordChanged := !bytes.Equal(before.Password.hash, user.Password.hash)
+
+		if before.Role != user.Role || passwordChanged {
+			_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1`, user.UserID)
+			if err != nil {
+				return err
+			}
+
+			err = revokeTokens(ctx, tx, user.UserID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return insertAudit(ctx, tx, actor, AuditUpdate, AuditTargetUser, user.UserID,
+			userAudit{User: before}, userAudit{User: user, PasswordChanged: passwordChanged})
+	})
 	if err != nil {
 		switch {
-		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+		case isUniqueViolation(err, "users_username_key"):
 			return ErrDuplicateUserName
-		case errors.Is(err, sql.ErrNoRows):
+		case errors.Is(err, ErrRecordNotFound):
 			return ErrEditConflict
 		default:
 			return err
@@ -177,7 +312,44 @@ func (m UserModel) Update(user *User) error {
 	return nil
 }
 
-func (m UserModel) GetByToken(tokenPlainText string) (*User, error) {
+// Delete removes the user along with their tokens, and records the
+// revocation for signed access tokens. The last admin can't be deleted.
+func (m UserModel) Delete(ctx context.Context, userID int64, actor Actor) error {
+	query := `
+        DELETE FROM users
+        WHERE user_id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
+	defer cancel()
+
+	return withTx(ctx, m.DB, func(tx *sql.Tx) error {
+		before, err := lockUser(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		if before.Role == RoleAdmin {
+			err = checkOtherAdmins(ctx, tx, userID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		err = revokeTokens(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+
+		return insertAudit(ctx, tx, actor, AuditDelete, AuditTargetUser, userID, before, nil)
+	})
+}
+
+func (m UserModel) GetByToken(ctx context.Context, tokenPlainText string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlainText))
 
 	query := `
@@ -189,7 +361,7 @@ func (m UserModel) GetByToken(tokenPlainText string) (*User, error) {
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, tokenHash[:]).Scan(
@@ -210,3 +382,76 @@ func (m UserModel) GetByToken(tokenPlainText string) (*User, error) {
 
 	return &user, nil
 }
+
+// checkOtherAdmins returns ErrLastAdmin unless an admin other than userID
+// exists. It locks every admin until the transaction ends, so that two
+// admins can't demote or delete each other at the same time.
+func checkOtherAdmins(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+        SELECT user_id
+        FROM users
+        WHERE role = 'admin'
+        FOR UPDATE`
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	others := 0
+
+	for rows.Next() {
+		var adminID int64
+
+		err := rows.Scan(&adminID)
+		if err != nil {
+			return err
+		}
+
+		if adminID != userID {
+			others++
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if others == 0 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
+// lockUser reads a user inside tx and holds a row lock on it until the
+// transaction ends.
+func lockUser(ctx context.Context, tx *sql.Tx, userID int64) (*User, error) {
+	query := `
+        SELECT user_id, username, role, password_hash, created_at
+        FROM users
+        WHERE user_id = $1
+        FOR UPDATE`
+
+	var user User
+
+	err := tx.QueryRowContext(ctx, query, userID).Scan(
+		&user.UserID,
+		&user.UserName,
+		&user.Role,
+		&user.Password.hash,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,263 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/skraio/banner-service/internal/validator"
+)
+
+type Webhook struct {
+	WebhookID  int64     `json:"webhook_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	FeatureIDs []int64   `json:"feature_ids"`
+	IsActive   bool      `json:"is_active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is one pending POST of a banner event to a webhook, claimed
+// from the webhook_deliveries outbox.
+type WebhookDelivery struct {
+	DeliveryID        int64
+	EventID           int64
+	EventType         string
+	BannerID          int64
+	Payload           json.RawMessage
+	PreviousFeatureID *int64
+	Attempts          int
+	CreatedAt         time.Time
+	URL               string
+	Secret            string
+}
+
+func GenerateWebhookSecret() (string, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(randomBytes), nil
+}
+
+func ValidateWebhook(v *validator.Validator, webhook *Webhook) {
+	u, err := url.Parse(webhook.URL)
+	v.Check(webhook.URL != "", "url", "must be provided")
+	v.Check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "url", "must be an absolute http or https URL")
+
+	v.Check(len(webhook.Secret) >= 16, "secret", "must be at least 16 bytes long")
+	v.Check(len(webhook.Secret) <= 128, "secret", "must not be more than 128 bytes long")
+
+	v.Check(len(webhook.FeatureIDs) >= 1, "feature_ids", "must contain at least 1 feature")
+	v.Check(len(webhook.FeatureIDs) <= 1000, "feature_ids", "must not contain more than 1000 features")
+	v.Check(validator.Unique(webhook.FeatureIDs), "feature_ids", "must not contain duplicate values")
+	v.Check(validator.Positive(webhook.FeatureIDs), "feature_ids", "must be positive")
+}
+
+type WebhookModel struct {
+	DB       *sql.DB
+	Timeouts Timeouts
+}
+
+func (m WebhookModel) Insert(ctx context.Context, webhook *Webhook) error {
+	query := `
+        INSERT INTO webhooks (url, secret, feature_ids, is_active)
+        VALUES ($1, $2, $3, $4)
+        RETURNING webhook_id, created_at`
+
+	args := []any{webhook.URL, webhook.Secret, webhook.FeatureIDs, webhook.IsActive}
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&webhook.WebhookID, &webhook.CreatedAt)
+}
+
+func (m WebhookModel) GetAll(ctx context.Context) ([]*Webhook, error) {
+	query := `
+        SELECT webhook_id, url, feature_ids, is_active, created_at
+        FROM webhooks
+        ORDER BY webhook_id`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Read)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	webhooks := []*Webhook{}
+
+	for rows.Next() {
+		var webhook Webhook
+
+		err := rows.Scan(
+			&webhook.WebhookID,
+			&webhook.URL,
+			pgArray{&webhook.FeatureIDs},
+			&webhook.IsActive,
+			&webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		webhooks = append(webhooks, &webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (m WebhookModel) Delete(ctx context.Context, webhookID int64) error {
+	if webhookID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+        DELETE FROM webhooks
+        WHERE webhook_id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, webhookID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// ClaimDeliveries takes up to limit due deliveries and pushes their next
+// attempt past lease, so a worker that dies mid-delivery only delays them.
+func (m WebhookModel) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*WebhookDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries d
+        SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+        FROM webhooks w
+        WHERE d.webhook_id = w.webhook_id AND d.delivery_id IN (
+            SELECT delivery_id
+            FROM webhook_deliveries
+            WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+            ORDER BY next_attempt_at
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED)
+        RETURNING d.delivery_id, d.event_id, d.event_type, d.banner_id, d.payload, d.previous_feature_id, d.attempts, d.created_at, w.url, w.secret`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	deliveries := []*WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery WebhookDelivery
+
+		err := rows.Scan(
+			&delivery.DeliveryID,
+			&delivery.EventID,
+			&delivery.EventType,
+			&delivery.BannerID,
+			&delivery.Payload,
+			&delivery.PreviousFeatureID,
+			&delivery.Attempts,
+			&delivery.CreatedAt,
+			&delivery.URL,
+			&delivery.Secret,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (m WebhookModel) MarkDelivered(ctx context.Context, deliveryID int64) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET delivered_at = NOW(), last_error = ''
+        WHERE delivery_id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, deliveryID)
+	return err
+}
+
+// MarkFailed schedules the next attempt after backoff, or gives up on the
+// delivery when giveUp is set.
+func (m WebhookModel) MarkFailed(ctx context.Context, deliveryID int64, cause error, backoff time.Duration, giveUp bool) error {
+	if cause == nil {
+		cause = errors.New("unknown error")
+	}
+
+	query := `
+        UPDATE webhook_deliveries
+        SET last_error = $2,
+            next_attempt_at = NOW() + make_interval(secs => $3),
+            failed_at = CASE WHEN $4 THEN NOW() END
+        WHERE delivery_id = $1`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Write)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, deliveryID, cause.Error(), backoff.Seconds(), giveUp)
+	return err
+}
+
+// PurgeDeliveries deletes deliveries that were delivered or given up on more
+// than retention ago.
+func (m WebhookModel) PurgeDeliveries(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `
+        DELETE FROM webhook_deliveries
+        WHERE (delivered_at IS NOT NULL OR failed_at IS NOT NULL)
+            AND coalesce(delivered_at, failed_at) < NOW() - make_interval(secs => $1)`
+
+	ctx, cancel := withTimeout(ctx, m.Timeouts.Bulk)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/skraio/banner-service/internal/testdb"
+)
+
+func TestClaimDeliveriesLeaseExpires(t *testing.T) {
+	models := NewModels(testdb.Open(t), nil, DefaultTimeouts)
+	ctx := context.Background()
+
+	err := models.Webhooks.Insert(ctx, &Webhook{
+		URL:        "http://127.0.0.1:1/hook",
+		Secret:     "0123456789abcdef",
+		FeatureIDs: []int64{1},
+		IsActive:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = models.Banners.Insert(ctx, &Banner{FeatureID: 1, TagIDs: []int64{1}, IsActive: true}, Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const lease = 500 * time.Millisecond
+
+	claim := func() []*WebhookDelivery {
+		t.Helper()
+
+		deliveries, err := models.Webhooks.ClaimDeliveries(ctx, 10, lease)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return deliveries
+	}
+
+	first := claim()
+	if len(first) != 1 || first[0].Attempts != 1 {
+		t.Fatalf("first claim = %d deliveries, want 1 at attempt 1", len(first))
+	}
+
+	if again := claim(); len(again) != 0 {
+		t.Fatalf("claimed %d leased deliveries, want 0", len(again))
+	}
+
+	// A worker that died never marks the delivery; once the lease runs out
+	// another one takes it over.
+	time.Sleep(lease + 100*time.Millisecond)
+
+	retaken := claim()
+	if len(retaken) != 1 {
+		t.Fatalf("claimed %d deliveries after the lease, want 1", len(retaken))
+	}
+	if retaken[0].DeliveryID != first[0].DeliveryID || retaken[0].Attempts != 2 {
+		t.Errorf("retaken delivery %d at attempt %d, want %d at attempt 2", retaken[0].DeliveryID, retaken[0].Attempts, first[0].DeliveryID)
+	}
+
+	err = models.Webhooks.MarkDelivered(ctx, retaken[0].DeliveryID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(lease + 100*time.Millisecond)
+
+	if done := claim(); len(done) != 0 {
+		t.Errorf("claimed %d delivered deliveries, want 0", len(done))
+	}
+}
+
+func TestDeliveriesFollowFeatureMoves(t *testing.T) {
+	models := NewModels(testdb.Open(t), nil, DefaultTimeouts)
+	ctx := context.Background()
+
+	for _, featureID := range []int64{1, 2, 3} {
+		hook := &Webhook{
+			URL:        "http://127.0.0.1:1/hook",
+			Secret:     "0123456789abcdef",
+			FeatureIDs: []int64{featureID},
+			IsActive:   true,
+		}
+
+		err := models.Webhooks.Insert(ctx, hook)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	banner := &Banner{FeatureID: 1, TagIDs: []int64{1}, IsActive: true}
+
+	err := models.Banners.Insert(ctx, banner, Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	banner.FeatureID = 2
+
+	err = models.Banners.Update(ctx, banner, Actor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := models.Webhooks.ClaimDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []*WebhookDelivery
+	for _, delivery := range deliveries {
+		if delivery.EventType == EventUpdated {
+			updates = append(updates, delivery)
+		}
+	}
+
+	// The old feature's webhook hears about the move too; the third
+	// feature's doesn't.
+	if len(updates) != 2 {
+		t.Fatalf("got %d update deliveries, want 2", len(updates))
+	}
+
+	for _, delivery := range updates {
+		if delivery.PreviousFeatureID == nil || *delivery.PreviousFeatureID != 1 {
+			t.Errorf("delivery %d has previous feature %v, want 1", delivery.DeliveryID, delivery.PreviousFeatureID)
+		}
+	}
+
+	events, _, err := models.Events.GetSince(ctx, EventPosition{}, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := events[len(events)-1]; last.Type != EventUpdated || last.PreviousFeatureID == nil || *last.PreviousFeatureID != 1 {
+		t.Errorf("last event is %s with previous feature %v, want updated from 1", last.Type, last.PreviousFeatureID)
+	}
+}
+
+func TestPurgeDeliveries(t *testing.T) {
+	models := NewModels(testdb.Open(t), nil, DefaultTimeouts)
+	ctx := context.Background()
+
+	err := models.Webhooks.Insert(ctx, &Webhook{
+		URL:        "http://127.0.0.1:1/hook",
+		Secret:     "0123456789abcdef",
+		FeatureIDs: []int64{1},
+		IsActive:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = models.Banners.Insert(ctx, &Banner{FeatureID: 1, TagIDs: []int64{int64(i + 1)}, IsActive: true}, Actor{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deliveries, err := models.Webhooks.ClaimDeliveries(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 3 {
+		t.Fatalf("claimed %d deliveries, want 3", len(deliveries))
+	}
+
+	if err := models.Webhooks.MarkDelivered(ctx, deliveries[0].DeliveryID); err != nil {
+		t.Fatal(err)
+	}
+	if err := models.Webhooks.MarkFailed(ctx, deliveries[1].DeliveryID, nil, time.Minute, true); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	// The pending delivery stays, however old.
+	purged, err := models.Webhooks.PurgeDeliveries(ctx, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 2 {
+		t.Errorf("purged %d deliveries, want 2", purged)
+	}
+}
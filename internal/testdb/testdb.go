@@ -0,0 +1,115 @@
+// Package testdb gives tests a migrated PostgreSQL database of their own.
+//
+// Tests that need one run only when BANNER_TEST_DSN points at a database the
+// tests may create schemas in; otherwise they are skipped.
+package testdb
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// EnvDSN names the variable holding the DSN of the test database.
+const EnvDSN = "BANNER_TEST_DSN"
+
+// DSN returns the DSN of the test database, skipping t when there is none.
+func DSN(t testing.TB) string {
+	t.Helper()
+
+	dsn := os.Getenv(EnvDSN)
+	if dsn == "" {
+		t.Skipf("%s is not set", EnvDSN)
+	}
+
+	return dsn
+}
+
+// Open returns a pool whose connections use a fresh schema with every
+// migration applied. The schema is dropped when the test ends.
+func Open(t testing.TB) *sql.DB {
+	t.Helper()
+
+	dsn := DSN(t)
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(suffix)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+
+	_, err = admin.ExecContext(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db, err := sql.Open("pgx", dsn)
+		if err != nil {
+			return
+		}
+		defer db.Close()
+
+		db.Exec("DROP SCHEMA " + pgx.Identifier{schema}.Sanitize() + " CASCADE")
+	})
+
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connConfig.RuntimeParams["search_path"] = schema + ",public"
+
+	db := sql.OpenDB(stdlib.GetConnector(*connConfig))
+	t.Cleanup(func() { db.Close() })
+
+	for _, file := range migrations(t) {
+		up, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = db.ExecContext(ctx, string(up))
+		if err != nil {
+			t.Fatalf("%s: %v", filepath.Base(file), err)
+		}
+	}
+
+	return db
+}
+
+func migrations(t testing.TB) []string {
+	t.Helper()
+
+	_, self, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("can't locate the migrations")
+	}
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(self), "..", "..", "migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+
+	sort.Strings(files)
+
+	return files
+}
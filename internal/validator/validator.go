@@ -2,7 +2,10 @@ package validator
 
 import "regexp"
 
-var UrlRX = regexp.MustCompile(`^(https?:\/\/)?([\da-z\.-]+)\.([a-z\.]{2,6})([\/\w \.-]*)*\/?$`)
+var (
+	UrlRX       = regexp.MustCompile(`^(https?:\/\/)?([\da-z\.-]+)\.([a-z\.]{2,6})([\/\w \.-]*)*\/?$`)
+	RequestIDRX = regexp.MustCompile(`^[\w.:-]{1,64}$`)
+)
 
 type Validator struct {
 	Errors map[string]string
@@ -0,0 +1,2 @@
+drop index if exists banners_deleted_at_idx;
+alter table banners drop column if exists deleted_at;
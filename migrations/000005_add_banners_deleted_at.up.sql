@@ -0,0 +1,2 @@
+alter table banners add column if not exists deleted_at timestamp(3) with time zone;
+create index if not exists banners_deleted_at_idx on banners (deleted_at) where deleted_at is not null;
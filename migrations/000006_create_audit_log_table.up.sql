@@ -0,0 +1,13 @@
+create table if not exists audit_log (
+    audit_id bigserial primary key,
+    actor_id bigint,
+    action text not null,
+    target_type text not null,
+    target_id bigint not null,
+    diff jsonb not null,
+    request_id text not null default '',
+    created_at timestamp(3) with time zone not null default now()
+);
+create index if not exists audit_log_actor_idx on audit_log (actor_id);
+create index if not exists audit_log_target_idx on audit_log (target_type, target_id);
+create index if not exists audit_log_created_at_idx on audit_log (created_at);
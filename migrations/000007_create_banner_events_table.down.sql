@@ -0,0 +1,3 @@
+drop trigger if exists banners_events_trigger on banners;
+drop function if exists banner_events_notify();
+drop table if exists banner_events;
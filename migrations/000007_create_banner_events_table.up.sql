@@ -0,0 +1,52 @@
+create table if not exists banner_events (
+    event_id bigserial primary key,
+    event_type text not null,
+    banner_id bigint not null,
+    payload jsonb not null,
+    created_at timestamp(3) with time zone not null default now()
+);
+create index if not exists banner_events_created_at_idx on banner_events (created_at);
+
+create or replace function banner_events_notify() returns trigger as $$
+declare
+    kind text;
+    row_data jsonb;
+    new_event_id bigint;
+begin
+    if tg_op = 'INSERT' then
+        kind := 'created';
+        row_data := to_jsonb(new);
+    elsif tg_op = 'DELETE' then
+        -- purging a banner from the trash was already announced as a delete
+        if old.deleted_at is not null then
+            return null;
+        end if;
+        kind := 'deleted';
+        row_data := to_jsonb(old);
+    elsif old.deleted_at is null and new.deleted_at is not null then
+        kind := 'deleted';
+        row_data := to_jsonb(new);
+    elsif old.deleted_at is not null and new.deleted_at is null then
+        kind := 'created';
+        row_data := to_jsonb(new);
+    elsif new.deleted_at is not null then
+        return null;
+    else
+        kind := 'updated';
+        row_data := to_jsonb(new);
+    end if;
+
+    insert into banner_events (event_type, banner_id, payload)
+    values (kind, (row_data->>'banner_id')::bigint, row_data)
+    returning event_id into new_event_id;
+
+    perform pg_notify('banner_events', new_event_id::text);
+
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists banners_events_trigger on banners;
+create trigger banners_events_trigger
+    after insert or update or delete on banners
+    for each row execute function banner_events_notify();
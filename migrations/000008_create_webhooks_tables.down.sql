@@ -0,0 +1,4 @@
+drop trigger if exists banner_events_webhooks_trigger on banner_events;
+drop function if exists webhook_deliveries_enqueue();
+drop table if exists webhook_deliveries;
+drop table if exists webhooks;
@@ -0,0 +1,42 @@
+create table if not exists webhooks (
+    webhook_id bigserial primary key,
+    url text not null,
+    secret text not null,
+    feature_ids bigint[] not null,
+    is_active boolean not null default true,
+    created_at timestamp(3) with time zone not null default now()
+);
+create index if not exists webhooks_feature_idxs on webhooks using gin (feature_ids);
+
+create table if not exists webhook_deliveries (
+    delivery_id bigserial primary key,
+    webhook_id bigint not null references webhooks(webhook_id) on delete cascade,
+    event_id bigint not null,
+    event_type text not null,
+    banner_id bigint not null,
+    payload jsonb not null,
+    attempts integer not null default 0,
+    next_attempt_at timestamp(3) with time zone not null default now(),
+    last_error text not null default '',
+    delivered_at timestamp(3) with time zone,
+    failed_at timestamp(3) with time zone,
+    created_at timestamp(3) with time zone not null default now()
+);
+create index if not exists webhook_deliveries_pending_idx on webhook_deliveries (next_attempt_at)
+    where delivered_at is null and failed_at is null;
+
+create or replace function webhook_deliveries_enqueue() returns trigger as $$
+begin
+    insert into webhook_deliveries (webhook_id, event_id, event_type, banner_id, payload)
+    select webhook_id, new.event_id, new.event_type, new.banner_id, new.payload
+    from webhooks
+    where is_active and (new.payload->>'feature_id')::bigint = any(feature_ids);
+
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists banner_events_webhooks_trigger on banner_events;
+create trigger banner_events_webhooks_trigger
+    after insert on banner_events
+    for each row execute function webhook_deliveries_enqueue();
@@ -0,0 +1,2 @@
+drop trigger if exists banners_cache_trigger on banners;
+drop function if exists banners_cache_notify();
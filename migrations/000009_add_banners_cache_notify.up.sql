@@ -0,0 +1,29 @@
+create or replace function banners_cache_notify() returns trigger as $$
+declare
+    pairs jsonb := '[]'::jsonb;
+    payload text;
+begin
+    if tg_op in ('UPDATE', 'DELETE') then
+        pairs := pairs || jsonb_build_object('feature_id', old.feature_id, 'tag_ids', to_jsonb(old.tag_ids));
+    end if;
+    if tg_op in ('INSERT', 'UPDATE') then
+        pairs := pairs || jsonb_build_object('feature_id', new.feature_id, 'tag_ids', to_jsonb(new.tag_ids));
+    end if;
+
+    payload := pairs::text;
+
+    -- NOTIFY payloads are limited to 8000 bytes
+    if length(payload) > 7900 then
+        payload := 'flush';
+    end if;
+
+    perform pg_notify('banner_cache', payload);
+
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists banners_cache_trigger on banners;
+create trigger banners_cache_trigger
+    after insert or update or delete on banners
+    for each row execute function banners_cache_notify();
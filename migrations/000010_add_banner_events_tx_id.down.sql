@@ -0,0 +1,2 @@
+drop index if exists banner_events_position_idx;
+alter table banner_events drop column if exists tx_id;
@@ -0,0 +1,4 @@
+-- event ids are taken before commit, so they don't follow commit order; the
+-- writing transaction's id lets readers stop at transactions still running
+alter table banner_events add column if not exists tx_id xid8 not null default pg_current_xact_id();
+create index if not exists banner_events_position_idx on banner_events (tx_id, event_id);
@@ -0,0 +1,3 @@
+drop trigger if exists token_revocations_trigger on token_revocations;
+drop function if exists token_revocations_notify();
+drop table if exists token_revocations;
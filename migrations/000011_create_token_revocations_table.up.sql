@@ -0,0 +1,18 @@
+-- signed access tokens are verified without the database, so every instance
+-- has to learn when a user's tokens were revoked; rows outlive the user
+create table if not exists token_revocations (
+    user_id bigint primary key,
+    revoked_before timestamp(0) with time zone not null
+);
+
+create or replace function token_revocations_notify() returns trigger as $$
+begin
+    perform pg_notify('token_revocations', new.user_id || ' ' || extract(epoch from new.revoked_before)::bigint);
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists token_revocations_trigger on token_revocations;
+create trigger token_revocations_trigger
+    after insert or update on token_revocations
+    for each row execute function token_revocations_notify();
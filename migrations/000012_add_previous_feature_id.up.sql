@@ -0,0 +1,63 @@
+-- an update that moves a banner to another feature must also reach the old
+-- feature's webhooks
+alter table banner_events add column if not exists previous_feature_id bigint;
+alter table webhook_deliveries add column if not exists previous_feature_id bigint;
+
+create index if not exists webhook_deliveries_finished_idx on webhook_deliveries (coalesce(delivered_at, failed_at))
+    where delivered_at is not null or failed_at is not null;
+
+create or replace function banner_events_notify() returns trigger as $$
+declare
+    kind text;
+    row_data jsonb;
+    previous_feature bigint;
+    new_event_id bigint;
+begin
+    if tg_op = 'INSERT' then
+        kind := 'created';
+        row_data := to_jsonb(new);
+    elsif tg_op = 'DELETE' then
+        -- purging a banner from the trash was already announced as a delete
+        if old.deleted_at is not null then
+            return null;
+        end if;
+        kind := 'deleted';
+        row_data := to_jsonb(old);
+    elsif old.deleted_at is null and new.deleted_at is not null then
+        kind := 'deleted';
+        row_data := to_jsonb(new);
+    elsif old.deleted_at is not null and new.deleted_at is null then
+        kind := 'created';
+        row_data := to_jsonb(new);
+    elsif new.deleted_at is not null then
+        return null;
+    else
+        kind := 'updated';
+        row_data := to_jsonb(new);
+    end if;
+
+    if tg_op = 'UPDATE' and old.feature_id <> new.feature_id then
+        previous_feature := old.feature_id;
+    end if;
+
+    insert into banner_events (event_type, banner_id, payload, previous_feature_id)
+    values (kind, (row_data->>'banner_id')::bigint, row_data, previous_feature)
+    returning event_id into new_event_id;
+
+    perform pg_notify('banner_events', new_event_id::text);
+
+    return null;
+end;
+$$ language plpgsql;
+
+create or replace function webhook_deliveries_enqueue() returns trigger as $$
+begin
+    insert into webhook_deliveries (webhook_id, event_id, event_type, banner_id, payload, previous_feature_id)
+    select webhook_id, new.event_id, new.event_type, new.banner_id, new.payload, new.previous_feature_id
+    from webhooks
+    where is_active
+        and ((new.payload->>'feature_id')::bigint = any(feature_ids) or new.previous_feature_id = any(feature_ids));
+
+    return null;
+end;
+$$ language plpgsql;
@@ -0,0 +1,9 @@
+// Package bannerservice holds the files shared by the service binaries.
+package bannerservice
+
+import _ "embed"
+
+// OpenAPI is the API specification, served by the API at /openapi.yaml.
+//
+//go:embed api.yaml
+var OpenAPI []byte